@@ -0,0 +1,91 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// RequestContextKey is the context key of the *app.RequestContext of the request served
+// by a handler of NewHertzHTTPHandler, the value is what RequestContextFromContext returns:
+//
+//	c, ok := r.Context().Value(adaptor.RequestContextKey{}).(*app.RequestContext)
+type RequestContextKey struct{}
+
+// requestContext is the context.Context of the *http.Request given to the net/http
+// handler. It is a single layer over the hertz context, whatever the number of keys.
+type requestContext struct {
+	context.Context
+	c    *app.RequestContext
+	keys map[string]interface{}
+}
+
+// init sets ctx up for c, copying the keys of c so that they can be looked up even once
+// the handler returned, when c is reused for another request.
+func (ctx *requestContext) init(parent context.Context, c *app.RequestContext) {
+	ctx.Context, ctx.c = parent, c
+	c.ForEachKey(func(k string, v interface{}) {
+		if ctx.keys == nil {
+			ctx.keys = make(map[string]interface{}, len(c.Keys))
+		}
+		ctx.keys[k] = v
+	})
+}
+
+// reset clears ctx to be reused, keeping the memory of its keys.
+func (ctx *requestContext) reset() {
+	for k := range ctx.keys {
+		delete(ctx.keys, k)
+	}
+	*ctx = requestContext{keys: ctx.keys}
+}
+
+// Value returns the *app.RequestContext for RequestContextKey. For compatibility, a string
+// key is also looked up in the keys the *app.RequestContext had when the handler was called,
+// as set by c.Set, before the parent context.
+func (ctx *requestContext) Value(key interface{}) interface{} {
+	switch k := key.(type) {
+	case RequestContextKey:
+		return ctx.c
+	case string:
+		if v, ok := ctx.keys[k]; ok {
+			return v
+		}
+	}
+	return ctx.Context.Value(key)
+}
+
+// RequestContextFromContext returns the *app.RequestContext of the request served by a
+// handler of NewHertzHTTPHandler, given the context of its *http.Request, so that the
+// net/http handler can reach the hertz request state, e.g. the keys set by middlewares:
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		if c, ok := adaptor.RequestContextFromContext(r.Context()); ok {
+//			user := c.GetString("user")
+//			...
+//		}
+//	}
+//
+// The *app.RequestContext must not be used once the handler returned, hertz then reuses it
+// for another request. Goroutines outliving the handler should rather copy what they need,
+// the string keys looked up with r.Context().Value stay valid though.
+func RequestContextFromContext(ctx context.Context) (*app.RequestContext, bool) {
+	c, ok := ctx.Value(RequestContextKey{}).(*app.RequestContext)
+	return c, ok
+}
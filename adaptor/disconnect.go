@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	hnetpoll "github.com/cloudwego/hertz/pkg/network/netpoll"
+)
+
+// disconnectCheckInterval is how often the connection is checked by WithCancelOnDisconnect.
+const disconnectCheckInterval = 100 * time.Millisecond
+
+// activeChecker is implemented by the connections able to tell whether the client is
+// still there, i.e. the ones of the netpoll transport. netpoll marks the connection
+// inactive as soon as the peer hangs up, but only runs its close callbacks once the
+// request is processed, so it has to be checked.
+type activeChecker interface {
+	IsActive() bool
+}
+
+// withDisconnectCancel returns a copy of ctx which is canceled once the client closes
+// the connection c is served on, along with the func to call once the request is done.
+// If the transport can not tell, ctx is only canceled by the returned func.
+func withDisconnectCancel(ctx context.Context, c *app.RequestContext) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var conn interface{} = c.GetConn()
+	if hc, ok := conn.(*hnetpoll.Conn); ok {
+		conn = hc.Conn
+	}
+	checker, ok := conn.(activeChecker)
+	if !ok {
+		return ctx, cancel
+	}
+
+	go func() {
+		ticker := time.NewTicker(disconnectCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !checker.IsActive() {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
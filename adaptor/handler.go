@@ -68,8 +68,8 @@ import (
 //
 // So it is advisable using this function only for net/http -> hertz switching.
 // Then manually convert net/http handlers to hertz handlers
-func NewHertzHTTPHandlerFunc(h http.HandlerFunc) app.HandlerFunc {
-	return NewHertzHTTPHandler(h)
+func NewHertzHTTPHandlerFunc(h http.HandlerFunc, opts ...Option) app.HandlerFunc {
+	return NewHertzHTTPHandler(h, opts...)
 }
 
 // NewHertzHTTPHandler wraps net/http handler to hertz app.HandlerFunc,
@@ -88,8 +88,21 @@ func NewHertzHTTPHandlerFunc(h http.HandlerFunc) app.HandlerFunc {
 //
 // So it is advisable using this function only for net/http -> hertz switching.
 // Then manually convert net/http handlers to hertz handlers
-func NewHertzHTTPHandler(h http.Handler) app.HandlerFunc {
+func NewHertzHTTPHandler(h http.Handler, opts ...Option) app.HandlerFunc {
+	o := newOptions(opts...)
 	return func(ctx context.Context, c *app.RequestContext) {
+		if o.cancelOnDisconnect {
+			var cancel context.CancelFunc
+			ctx, cancel = withDisconnectCancel(ctx, c)
+			defer cancel()
+		}
+		if o.requestPool {
+			p := acquireRequest(ctx, c, o.streaming)
+			h.ServeHTTP(&p.rw, p.req)
+			p.rw.finish()
+			releaseRequest(p)
+			return
+		}
 		req, err := adaptor.GetCompatRequest(&c.Request)
 		if err != nil {
 			hlog.CtxErrorf(ctx, "HERTZ: Get request error: %v", err)
@@ -97,22 +110,12 @@ func NewHertzHTTPHandler(h http.Handler) app.HandlerFunc {
 			return
 		}
 		req.RequestURI = b2s(c.Request.RequestURI())
-		rw := adaptor.GetCompatResponseWriter(&c.Response)
-		c.ForEachKey(func(k string, v interface{}) {
-			ctx = context.WithValue(ctx, k, v)
-		})
+		rctx := &requestContext{}
+		rctx.init(ctx, c)
+		ctx = rctx
+		rw := newResponseWriter(c, o.streaming)
 		h.ServeHTTP(rw, req.WithContext(ctx))
-		body := c.Response.Body()
-		// From net/http.ResponseWriter.Write:
-		// If the Header does not contain a Content-Type line, Write adds a Content-Type set
-		// to the result of passing the initial 512 bytes of written data to DetectContentType.
-		if len(c.GetHeader(consts.HeaderContentType)) == 0 {
-			l := 512
-			if len(body) < 512 {
-				l = len(body)
-			}
-			c.Response.Header.Set(consts.HeaderContentType, http.DetectContentType(body[:l]))
-		}
+		rw.finish()
 	}
 }
 
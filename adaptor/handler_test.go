@@ -42,8 +42,12 @@
 package adaptor
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -56,6 +60,9 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/adaptor"
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/network/netpoll"
+	"github.com/cloudwego/hertz/pkg/network/standard"
 	"github.com/cloudwego/hertz/pkg/protocol"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"github.com/cloudwego/hertz/pkg/route"
@@ -513,3 +520,510 @@ func TestFile(t *testing.T) {
 	err := c.Do(context.Background(), req, resp)
 	assert.Nil(t, err)
 }
+
+func TestStreaming(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10051"
+	engine := route.NewEngine(opt)
+	received := make(chan struct{})
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Stream", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("first")) // nolint:errcheck
+		flusher, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		flusher.Flush()
+		// the second chunk is only written once the client got the first one
+		<-received
+		w.Write([]byte("second")) // nolint:errcheck
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler), WithStreaming()))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10051/")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.DeepEqual(t, http.StatusAccepted, resp.StatusCode)
+	assert.DeepEqual(t, "yes", resp.Header.Get("X-Stream"))
+	assert.DeepEqual(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.DeepEqual(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	first := make([]byte, len("first"))
+	_, err = io.ReadFull(resp.Body, first)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "first", string(first))
+	close(received)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "second", string(rest))
+}
+
+func TestStreamingWithoutFlush(t *testing.T) {
+	t.Parallel()
+
+	for i, transporter := range []func(*config.Options) network.Transporter{netpoll.NewTransporter, standard.NewTransporter} {
+		opt := config.NewOptions([]config.Option{})
+		opt.Addr = fmt.Sprintf("127.0.0.1:%d", 10058+i)
+		opt.TransporterNewer = transporter
+		engine := route.NewEngine(opt)
+		chunk := strings.Repeat("x", 8<<10)
+		received := make(chan struct{})
+		handler := func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(chunk)) // nolint:errcheck
+			// the handler never flushes, the client gets the chunk nevertheless
+			<-received
+			w.Write([]byte(chunk)) // nolint:errcheck
+		}
+
+		engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler), WithStreaming()))
+		go engine.Run()
+		time.Sleep(time.Millisecond * 500)
+
+		resp, err := http.Get("http://" + opt.Addr + "/")
+		assert.Nil(t, err)
+		first := make([]byte, len(chunk))
+		_, err = io.ReadFull(resp.Body, first)
+		assert.Nil(t, err)
+		close(received)
+
+		rest, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.DeepEqual(t, len(chunk), len(rest))
+		resp.Body.Close()
+		engine.Close()
+	}
+}
+
+func TestStreamingReadFrom(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10060"
+	engine := route.NewEngine(opt)
+	chunk := strings.Repeat("x", 8<<10)
+	received := make(chan struct{})
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.Write([]byte(chunk)) // nolint:errcheck
+			<-received
+			pw.Write([]byte(chunk)) // nolint:errcheck
+			pw.Close()
+		}()
+		_, ok := w.(io.ReaderFrom)
+		assert.True(t, ok)
+		io.Copy(w, pr) // nolint:errcheck
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler), WithStreaming()))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10060/")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	first := make([]byte, len(chunk))
+	_, err = io.ReadFull(resp.Body, first)
+	assert.Nil(t, err)
+	close(received)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, len(chunk), len(rest))
+}
+
+func TestStreamingWithoutBody(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10052"
+	engine := route.NewEngine(opt)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Empty", "yes")
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	engine.GET("/", func(ctx context.Context, c *app.RequestContext) {
+		c.Response.Header.Set("X-Previous", "yes")
+		c.Next(ctx)
+	}, NewHertzHTTPHandler(http.HandlerFunc(handler), WithStreaming()))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10052/")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.DeepEqual(t, http.StatusNoContent, resp.StatusCode)
+	assert.DeepEqual(t, "yes", resp.Header.Get("X-Empty"))
+	assert.DeepEqual(t, []string{"yes"}, resp.Header.Values("X-Previous"))
+}
+
+func TestCancelOnDisconnect(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10053"
+	engine := route.NewEngine(opt)
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			canceled <- req.Context().Err()
+		case <-time.After(5 * time.Second):
+			canceled <- nil
+		}
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler), WithCancelOnDisconnect()))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:10053")
+	assert.Nil(t, err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: 127.0.0.1\r\n\r\n"))
+	assert.Nil(t, err)
+	<-started
+	conn.Close()
+
+	select {
+	case err = <-canceled:
+		assert.DeepEqual(t, context.Canceled, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("request context not canceled after client disconnected")
+	}
+}
+
+func TestKeepAliveRequestsNotCanceled(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10054"
+	engine := route.NewEngine(opt)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(fmt.Sprint(req.Context().Err()))) // nolint:errcheck
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler), WithCancelOnDisconnect()))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	c, _ := client.NewClient()
+	for i := 0; i < 3; i++ {
+		status, body, err := c.Get(context.Background(), nil, "http://127.0.0.1:10054/")
+		assert.Nil(t, err)
+		assert.DeepEqual(t, http.StatusOK, status)
+		assert.DeepEqual(t, "<nil>", string(body))
+	}
+}
+
+func TestFlushWithoutStreaming(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10055"
+	engine := route.NewEngine(opt)
+	received := make(chan struct{})
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-received
+		fmt.Fprint(w, "data: second\n\n")
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler)))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10055/")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.DeepEqual(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.DeepEqual(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	first := make([]byte, len("data: first\n\n"))
+	_, err = io.ReadFull(resp.Body, first)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "data: first\n\n", string(first))
+	close(received)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "data: second\n\n", string(rest))
+}
+
+func TestHijack(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10056"
+	engine := route.NewEngine(opt)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+		_, _, err = w.(http.Hijacker).Hijack()
+		assert.DeepEqual(t, http.ErrHijacked, err)
+		_, err = w.Write([]byte("ignored"))
+		assert.DeepEqual(t, http.ErrHijacked, err)
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n") // nolint:errcheck
+		rw.Flush()                                                                                         // nolint:errcheck
+		// the connection outlives the handler, as with net/http
+		go func() {
+			defer conn.Close()
+			line, err := rw.ReadString('\n')
+			assert.Nil(t, err)
+			rw.WriteString(line) // nolint:errcheck
+			rw.Flush()           // nolint:errcheck
+		}()
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler)))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:10056")
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: 127.0.0.1\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n"))
+	assert.Nil(t, err)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	_, err = conn.Write([]byte("ping\n"))
+	assert.Nil(t, err)
+	line, err := br.ReadString('\n')
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "ping\n", line)
+
+	// the handler closed the connection
+	_, err = br.ReadByte()
+	assert.DeepEqual(t, io.EOF, err)
+}
+
+func TestTrailers(t *testing.T) {
+	t.Parallel()
+
+	opt := config.NewOptions([]config.Option{})
+	opt.Addr = "127.0.0.1:10057"
+	engine := route.NewEngine(opt)
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("body")) // nolint:errcheck
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Late", "def")
+	}
+
+	engine.GET("/", NewHertzHTTPHandler(http.HandlerFunc(handler)))
+	go engine.Run()
+	defer func() {
+		engine.Close()
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10057/")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "body", string(body))
+	assert.DeepEqual(t, "abc", resp.Trailer.Get("X-Checksum"))
+	assert.DeepEqual(t, "def", resp.Trailer.Get("X-Late"))
+	assert.DeepEqual(t, "", resp.Header.Get("X-Checksum"))
+}
+
+func TestResponseWriterWithoutConn(t *testing.T) {
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("<html></html>"))
+		assert.Nil(t, err)
+		assert.DeepEqual(t, int64(len("<html></html>")), n)
+
+		_, _, err = w.(http.Hijacker).Hijack()
+		assert.DeepEqual(t, http.ErrNotSupported, err)
+		err = w.(interface{ SetReadDeadline(time.Time) error }).SetReadDeadline(time.Now())
+		assert.DeepEqual(t, http.ErrNotSupported, err)
+		err = w.(interface{ SetWriteDeadline(time.Time) error }).SetWriteDeadline(time.Now())
+		assert.DeepEqual(t, http.ErrNotSupported, err)
+		assert.Nil(t, w.(interface{ FlushError() error }).FlushError())
+	}
+
+	var c app.RequestContext
+	c.Request.SetRequestURI("/")
+	NewHertzHTTPHandler(http.HandlerFunc(handler))(context.Background(), &c)
+	assert.DeepEqual(t, "<html></html>", string(c.Response.Body()))
+	assert.DeepEqual(t, "text/html; charset=utf-8", string(c.Response.Header.ContentType()))
+}
+
+type testContextKey struct{}
+
+func TestRequestContextFromContext(t *testing.T) {
+	var c app.RequestContext
+	c.Request.SetRequestURI("/")
+	c.Set("user", "alice")
+
+	var kept context.Context
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		got, ok := RequestContextFromContext(req.Context())
+		assert.True(t, ok)
+		assert.True(t, got == &c)
+		assert.DeepEqual(t, "alice", got.GetString("user"))
+
+		assert.True(t, req.Context().Value(RequestContextKey{}) == &c)
+		assert.DeepEqual(t, "alice", req.Context().Value("user"))
+		assert.DeepEqual(t, "typed", req.Context().Value(testContextKey{}))
+		assert.DeepEqual(t, "parent", req.Context().Value("parent"))
+		kept = req.Context()
+	}
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "typed")
+	ctx = context.WithValue(ctx, "parent", "parent") // nolint:staticcheck
+	NewHertzHTTPHandler(http.HandlerFunc(handler))(ctx, &c)
+
+	// the keys are copied, they outlive the request context being reused
+	c.Reset()
+	c.Set("user", "bob")
+	assert.DeepEqual(t, "alice", kept.Value("user"))
+
+	_, ok := RequestContextFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+type requestSnapshot struct {
+	Method        string
+	URL           string
+	Path          string
+	RawPath       string
+	Proto         string
+	Host          string
+	RequestURI    string
+	ContentLength int64
+	Header        http.Header
+	Body          string
+	ContextValue  interface{}
+}
+
+func snapshotRequest(r *http.Request) requestSnapshot {
+	body, _ := ioutil.ReadAll(r.Body)
+	header := make(http.Header)
+	for k, v := range r.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	return requestSnapshot{
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		Path:          r.URL.Path,
+		RawPath:       r.URL.RawPath,
+		Proto:         r.Proto,
+		Host:          r.Host,
+		RequestURI:    r.RequestURI,
+		ContentLength: r.ContentLength,
+		Header:        header,
+		Body:          string(body),
+		ContextValue:  r.Context().Value("key"),
+	}
+}
+
+func TestRequestPool(t *testing.T) {
+	newContext := func(uri, body string) *app.RequestContext {
+		c := &app.RequestContext{}
+		c.Request.Header.SetMethod(consts.MethodPost)
+		c.Request.SetRequestURI(uri)
+		c.Request.Header.Set("X-Multi", "a")
+		c.Request.Header.Add("X-Multi", "b")
+		c.Request.Header.Set("X-Single", "c")
+		c.Request.SetBodyString(body)
+		c.Set("key", "value")
+		return c
+	}
+
+	var snapshots []requestSnapshot
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		snapshots = append(snapshots, snapshotRequest(r))
+		w.Header().Set("X-Reply", "yes")
+		w.Write([]byte("<html></html>")) // nolint:errcheck
+	}
+
+	for _, uri := range []string{"http://foobar.com/foo/bar?baz=123", "http://foobar.com/a%2Fb/c", "/"} {
+		for _, body := range []string{"", "body"} {
+			snapshots = nil
+			want := newContext(uri, body)
+			NewHertzHTTPHandler(http.HandlerFunc(handler))(context.Background(), want)
+			// twice, for the second one to be served with the pooled memory of the first one
+			for i := 0; i < 2; i++ {
+				got := newContext(uri, body)
+				NewHertzHTTPHandler(http.HandlerFunc(handler), WithRequestPool())(context.Background(), got)
+				assert.DeepEqual(t, want.Response.StatusCode(), got.Response.StatusCode())
+				assert.DeepEqual(t, string(want.Response.Body()), string(got.Response.Body()))
+				assert.DeepEqual(t, string(want.Response.Header.ContentType()), string(got.Response.Header.ContentType()))
+				assert.DeepEqual(t, "yes", string(got.Response.Header.Peek("X-Reply")))
+			}
+			assert.DeepEqual(t, 3, len(snapshots))
+			assert.DeepEqual(t, snapshots[0], snapshots[1])
+			assert.DeepEqual(t, snapshots[0], snapshots[2])
+		}
+	}
+}
+
+func benchmarkHertzHTTPHandler(b *testing.B, opts ...Option) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(r.Header.Get("X-Request-Id"))) // nolint:errcheck
+	}
+	h := NewHertzHTTPHandler(http.HandlerFunc(handler), opts...)
+
+	c := &app.RequestContext{}
+	c.Request.Header.SetMethod(consts.MethodGet)
+	c.Request.SetRequestURI("http://foobar.com/debug/pprof/heap?debug=1")
+	c.Request.Header.Set("X-Request-Id", "123")
+	c.Request.Header.Set("User-Agent", "bench")
+	c.Request.Header.Set("Accept", "*/*")
+	c.Set("key", "value")
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h(ctx, c)
+		c.Response.Reset()
+	}
+}
+
+func BenchmarkNewHertzHTTPHandler(b *testing.B) {
+	benchmarkHertzHTTPHandler(b)
+}
+
+func BenchmarkNewHertzHTTPHandlerWithRequestPool(b *testing.B) {
+	benchmarkHertzHTTPHandler(b, WithRequestPool())
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/network"
+)
+
+// hijackedConn is the connection handed over by Hijack. Closing it lets the
+// hertz handler return, hertz then closes the connection.
+type hijackedConn struct {
+	network.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newHijackedConn(conn network.Conn) *hijackedConn {
+	return &hijackedConn{
+		Conn:   conn,
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *hijackedConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// closeHijackedConn is the hertz hijack handler, run once the hijacked connection
+// was closed. hertz leaves it open with server.WithKeepHijackedConns otherwise.
+func closeHijackedConn(conn network.Conn) {
+	conn.Close() // nolint:errcheck
+}
+
+func (c *hijackedConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *hijackedConn) SetReadDeadline(t time.Time) error {
+	return c.Conn.SetReadTimeout(deadlineTimeout(t))
+}
+
+func (c *hijackedConn) SetWriteDeadline(t time.Time) error {
+	return c.Conn.SetWriteTimeout(deadlineTimeout(t))
+}
+
+// deadlineTimeout converts a net.Conn deadline to a hertz connection timeout,
+// a zero value meaning none for both.
+func deadlineTimeout(t time.Time) time.Duration {
+	if t.IsZero() {
+		return 0
+	}
+	if d := time.Until(t); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
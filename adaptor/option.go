@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+// Option configures the handler returned by NewHertzHTTPHandler and NewHertzHTTPHandlerFunc.
+type Option func(o *options)
+
+type options struct {
+	streaming          bool
+	cancelOnDisconnect bool
+	requestPool        bool
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithStreaming sends what the net/http handler writes to the client as it comes,
+// in chunked transfer encoding, instead of buffering the whole body in the hertz
+// response. Like net/http, the body is sent whenever 4KB of it are buffered, or
+// when the handler flushes it through http.Flusher. It is meant for long running
+// handlers producing a large body, e.g. an execution trace.
+//
+// Once the first byte is written, the status and headers can not be changed anymore,
+// like with net/http. Streaming requires the request to be served
+// on a connection, otherwise the body is buffered as usual.
+func WithStreaming() Option {
+	return func(o *options) {
+		o.streaming = true
+	}
+}
+
+// WithCancelOnDisconnect cancels the context of the *http.Request once the client closes
+// the connection, so that long running handlers, e.g. a 2 minutes CPU profile, can stop
+// early. It is only supported by the netpoll transport, and costs a goroutine checking
+// the connection every 100ms for the duration of the request.
+func WithCancelOnDisconnect() Option {
+	return func(o *options) {
+		o.cancelOnDisconnect = true
+	}
+}
+
+// WithRequestPool reuses the *http.Request, its header and body, and the http.ResponseWriter
+// from one request to another, and shares the memory of the hertz request rather than copying
+// it, for handlers on hot paths. The handler must then neither keep the request, its context,
+// or any of its strings, nor the response writer, once it returned.
+func WithRequestPool() Option {
+	return func(o *options) {
+		o.requestPool = true
+	}
+}
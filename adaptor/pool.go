@@ -0,0 +1,148 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+var requestPool = sync.Pool{
+	New: func() interface{} {
+		p := &pooledRequest{
+			req:    new(http.Request),
+			header: make(http.Header),
+			rw:     responseWriter{header: make(http.Header)},
+		}
+		// the context of a *http.Request can only be set by copying it,
+		// keep one to reset the request with.
+		p.base = (&http.Request{}).WithContext(&p.ctx)
+		return p
+	},
+}
+
+// pooledRequest is what WithRequestPool reuses from one request to another.
+type pooledRequest struct {
+	base   *http.Request
+	req    *http.Request
+	url    url.URL
+	header http.Header
+	values []string
+	body   bodyReader
+	ctx    requestContext
+	rw     responseWriter
+}
+
+// bodyReader is the request body, a pointer to it is an io.ReadCloser without allocation.
+type bodyReader struct {
+	bytes.Reader
+}
+
+func (*bodyReader) Close() error {
+	return nil
+}
+
+// acquireRequest returns the pooled *http.Request and http.ResponseWriter for c,
+// the strings of the request share the memory of c.
+func acquireRequest(ctx context.Context, c *app.RequestContext, streaming bool) *pooledRequest {
+	p := requestPool.Get().(*pooledRequest)
+	p.ctx.init(ctx, c)
+
+	r := p.req
+	*r = *p.base
+
+	uri := c.Request.URI()
+	p.url = url.URL{
+		Scheme:   b2s(uri.Scheme()),
+		Host:     b2s(uri.Host()),
+		Path:     b2s(uri.Path()),
+		RawQuery: b2s(uri.QueryString()),
+	}
+	r.URL = &p.url
+	r.Method = method(c.Request.Header.Method())
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.1", 1, 1
+	if c.Request.Header.GetProtocol() == "HTTP/1.0" {
+		r.Proto, r.ProtoMinor = "HTTP/1.0", 0
+	}
+	r.Host = b2s(c.Request.Host())
+	r.RequestURI = b2s(c.Request.RequestURI())
+
+	c.Request.Header.VisitAll(func(k, v []byte) {
+		p.values = append(p.values, b2s(v))
+		i := len(p.values) - 1
+		if vv, ok := p.header[b2s(k)]; ok {
+			p.header[b2s(k)] = append(vv, p.values[i])
+			return
+		}
+		// the capacity is the length so that the next value is not appended in place
+		p.header[b2s(k)] = p.values[i : i+1 : i+1]
+	})
+	r.Header = p.header
+
+	r.Body = http.NoBody
+	if body := c.Request.Body(); len(body) > 0 {
+		p.body.Reset(body)
+		r.Body = &p.body
+		r.ContentLength = int64(len(body))
+	}
+
+	p.rw.init(c, streaming)
+	return p
+}
+
+// releaseRequest puts back p once the handler returned, it must not be used anymore.
+func releaseRequest(p *pooledRequest) {
+	for k := range p.header {
+		delete(p.header, k)
+	}
+	for i := range p.values {
+		p.values[i] = ""
+	}
+	p.values = p.values[:0]
+	p.body.Reset(nil)
+	p.url = url.URL{}
+	*p.req = http.Request{}
+	p.ctx.reset()
+	p.rw.reset()
+	requestPool.Put(p)
+}
+
+// method returns the net/http constant for the usual methods, without allocation.
+func method(m []byte) string {
+	switch string(m) {
+	case http.MethodGet:
+		return http.MethodGet
+	case http.MethodHead:
+		return http.MethodHead
+	case http.MethodPost:
+		return http.MethodPost
+	case http.MethodPut:
+		return http.MethodPut
+	case http.MethodPatch:
+		return http.MethodPatch
+	case http.MethodDelete:
+		return http.MethodDelete
+	case http.MethodOptions:
+		return http.MethodOptions
+	}
+	return string(m)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var (
+	errConnNotSupported = errors.New("adaptor: the connection of a net/http request can not be used")
+
+	requestContextPool = sync.Pool{
+		New: func() interface{} {
+			return app.NewContext(0)
+		},
+	}
+)
+
+// NewHTTPHandler wraps the hertz handlers to a net/http handler, so they can be served by a
+// net/http server, e.g. hertz middlewares and debug handlers in a service still on net/http.
+// The handlers are run as a chain, in order, like the ones of a hertz route.
+//
+// The *app.RequestContext is built from the *http.Request, the body being read from it as
+// a stream, and its response is written back once the chain returned. The connection is not
+// available to the handlers, so they can neither hijack it nor write the response themselves.
+func NewHTTPHandler(handlers ...app.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := requestContextPool.Get().(*app.RequestContext)
+		defer func() {
+			c.Reset()
+			requestContextPool.Put(c)
+		}()
+
+		copyHTTPRequest(r, c)
+		c.SetConn(&httpConn{remote: r.RemoteAddr})
+		c.SetHandlers(handlers)
+		c.Next(r.Context())
+
+		if err := writeHTTPResponse(w, c); err != nil {
+			hlog.CtxErrorf(r.Context(), "HERTZ: Write response error: %v", err)
+		}
+	})
+}
+
+// copyHTTPRequest copies r to the request of c, which reads the body of r as a stream.
+func copyHTTPRequest(r *http.Request, c *app.RequestContext) {
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	c.Request.SetRequestURI(uri)
+	c.Request.Header.SetMethod(r.Method)
+	c.Request.Header.SetProtocol(r.Proto)
+	for k, v := range r.Header {
+		for _, vv := range v {
+			c.Request.Header.Add(k, vv)
+		}
+	}
+	c.Request.SetHost(r.Host)
+	if r.Body != nil && r.Body != http.NoBody {
+		c.Request.SetBodyStream(r.Body, int(r.ContentLength))
+	}
+}
+
+// writeHTTPResponse writes the response of c to w.
+func writeHTTPResponse(w http.ResponseWriter, c *app.RequestContext) error {
+	header := w.Header()
+	c.Response.Header.VisitAll(func(k, v []byte) {
+		switch string(k) {
+		case consts.HeaderContentLength, consts.HeaderConnection, consts.HeaderTrailer:
+			// up to net/http
+		default:
+			header.Add(string(k), string(v))
+		}
+	})
+
+	if c.Response.IsBodyStream() {
+		w.WriteHeader(c.Response.StatusCode())
+		_, err := io.Copy(w, c.Response.BodyStream())
+		c.Response.CloseBodyStream() // nolint:errcheck
+		c.Response.Header.Trailer().VisitAll(func(k, v []byte) {
+			header.Add(http.TrailerPrefix+string(k), string(v))
+		})
+		return err
+	}
+
+	body := c.Response.Body()
+	c.Response.Header.Trailer().VisitAll(func(k, v []byte) {
+		header.Add(http.TrailerPrefix+string(k), string(v))
+	})
+	header.Set(consts.HeaderContentLength, strconv.Itoa(len(body)))
+	w.WriteHeader(c.Response.StatusCode())
+	_, err := w.Write(body)
+	return err
+}
+
+// httpConn is the connection of the *app.RequestContext of NewHTTPHandler, which only
+// tells the address of the client, for c.RemoteAddr and c.ClientIP.
+type httpConn struct {
+	remote string
+}
+
+func (c *httpConn) RemoteAddr() net.Addr {
+	host, port, err := net.SplitHostPort(c.remote)
+	if err != nil {
+		return &net.TCPAddr{IP: net.IPv4zero}
+	}
+	p, _ := strconv.Atoi(port)
+	return &net.TCPAddr{IP: net.ParseIP(host), Port: p}
+}
+
+func (c *httpConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4zero}
+}
+
+func (c *httpConn) Read(b []byte) (int, error)          { return 0, errConnNotSupported }
+func (c *httpConn) Write(b []byte) (int, error)         { return 0, errConnNotSupported }
+func (c *httpConn) Close() error                        { return errConnNotSupported }
+func (c *httpConn) SetDeadline(t time.Time) error       { return errConnNotSupported }
+func (c *httpConn) SetReadDeadline(t time.Time) error   { return errConnNotSupported }
+func (c *httpConn) SetWriteDeadline(t time.Time) error  { return errConnNotSupported }
+func (c *httpConn) SetReadTimeout(time.Duration) error  { return errConnNotSupported }
+func (c *httpConn) SetWriteTimeout(time.Duration) error { return errConnNotSupported }
+func (c *httpConn) Peek(n int) ([]byte, error)          { return nil, errConnNotSupported }
+func (c *httpConn) Skip(n int) error                    { return errConnNotSupported }
+func (c *httpConn) Release() error                      { return nil }
+func (c *httpConn) Len() int                            { return 0 }
+func (c *httpConn) ReadByte() (byte, error)             { return 0, errConnNotSupported }
+func (c *httpConn) ReadBinary(n int) ([]byte, error)    { return nil, errConnNotSupported }
+func (c *httpConn) Malloc(n int) ([]byte, error)        { return nil, errConnNotSupported }
+func (c *httpConn) WriteBinary(b []byte) (int, error)   { return 0, errConnNotSupported }
+func (c *httpConn) Flush() error                        { return errConnNotSupported }
+
+var _ network.Conn = (*httpConn)(nil)
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+func TestNewHTTPHandler(t *testing.T) {
+	middleware := func(ctx context.Context, c *app.RequestContext) {
+		if string(c.GetHeader("Authorization")) != "token" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set("user", "alice")
+		c.Next(ctx)
+		c.Header("X-Middleware", "after")
+	}
+	handler := func(ctx context.Context, c *app.RequestContext) {
+		assert.DeepEqual(t, "/echo", string(c.Path()))
+		assert.DeepEqual(t, "example.com", string(c.Host()))
+		assert.DeepEqual(t, "127.0.0.1", c.ClientIP())
+		c.SetCookie("session", "abc", 0, "/", "", 0, false, true)
+		c.Header("X-User", c.GetString("user"))
+		c.String(http.StatusCreated, "%s %s %s", c.Query("name"), c.Request.Header.Get("X-Echo"), c.Request.Body())
+	}
+
+	srv := httptest.NewServer(NewHTTPHandler(middleware, handler))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/echo?name=hertz", strings.NewReader("body"))
+	assert.Nil(t, err)
+	req.Host = "example.com"
+	req.Header.Set("X-Echo", "header")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, http.StatusForbidden, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodPost, srv.URL+"/echo?name=hertz", strings.NewReader("body"))
+	assert.Nil(t, err)
+	req.Host = "example.com"
+	req.Header.Set("X-Echo", "header")
+	req.Header.Set("Authorization", "token")
+
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, http.StatusCreated, resp.StatusCode)
+	assert.DeepEqual(t, "hertz header body", string(body))
+	assert.DeepEqual(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.DeepEqual(t, "alice", resp.Header.Get("X-User"))
+	assert.DeepEqual(t, "after", resp.Header.Get("X-Middleware"))
+	assert.DeepEqual(t, int64(len(body)), resp.ContentLength)
+	assert.DeepEqual(t, 1, len(resp.Cookies()))
+	assert.DeepEqual(t, "abc", resp.Cookies()[0].Value)
+}
+
+func TestNewHTTPHandlerBodyStream(t *testing.T) {
+	handler := func(ctx context.Context, c *app.RequestContext) {
+		c.Response.Header.Trailer().Set("X-Checksum", "abc") // nolint:errcheck
+		c.SetBodyStream(strings.NewReader("streamed"), -1)
+	}
+
+	srv := httptest.NewServer(NewHTTPHandler(handler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "streamed", string(body))
+	assert.DeepEqual(t, "abc", resp.Trailer.Get("X-Checksum"))
+}
+
+func TestRoundTripHertz(t *testing.T) {
+	// hertz -> net/http -> hertz, the response must be the one of the innermost handler
+	inner := func(ctx context.Context, c *app.RequestContext) {
+		c.Header("X-Inner", string(c.Request.Header.Get("X-Outer")))
+		c.JSON(http.StatusAccepted, map[string]string{"path": string(c.Path()), "body": string(c.Request.Body())})
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/round/trip", NewHertzHTTPHandler(NewHTTPHandler(inner)))
+
+	resp := ut.PerformRequest(engine, http.MethodPost, "/round/trip", &ut.Body{Body: strings.NewReader("ping"), Len: 4},
+		ut.Header{Key: "X-Outer", Value: "outer"})
+	assert.DeepEqual(t, http.StatusAccepted, resp.Code)
+	assert.DeepEqual(t, "outer", resp.Header().Get("X-Inner"))
+	assert.DeepEqual(t, consts.MIMEApplicationJSONUTF8, resp.Header().Get("Content-Type"))
+	assert.DeepEqual(t, `{"body":"ping","path":"/round/trip"}`, string(resp.Body.Bytes()))
+}
+
+func TestRoundTripHTTP(t *testing.T) {
+	// net/http -> hertz -> net/http, the hertz request has no connection to stream to or hijack
+	inner := func(w http.ResponseWriter, r *http.Request) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.DeepEqual(t, http.ErrNotSupported, err)
+		w.Header().Set("X-Inner", r.Header.Get("X-Outer"))
+		w.Write([]byte("first ")) // nolint:errcheck
+		w.(http.Flusher).Flush()
+		w.Write([]byte("second")) // nolint:errcheck
+	}
+
+	srv := httptest.NewServer(NewHTTPHandler(NewHertzHTTPHandler(http.HandlerFunc(inner), WithStreaming())))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	assert.Nil(t, err)
+	req.Header.Set("X-Outer", "outer")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, "outer", resp.Header.Get("X-Inner"))
+	assert.DeepEqual(t, "first second", string(body))
+}
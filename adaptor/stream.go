@@ -0,0 +1,137 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/ext"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/resp"
+)
+
+// streamFlushSize is the size of the chunks buffered by chunkedWriter before they are
+// sent to the client, the same as the buffer of a net/http response.
+const streamFlushSize = 4 << 10
+
+// chunkedWriter writes the response with chunked transfer encoding.
+// Unlike resp.NewChunkedBodyWriter, it can send the header without a chunk, and copies
+// the chunks to the buffer of the connection so that the caller may reuse them.
+// The buffer is flushed once it holds streamFlushSize bytes of chunks, so that the body
+// is neither kept in memory nor held from the client until the handler returns.
+type chunkedWriter struct {
+	r           *protocol.Response
+	w           network.Writer
+	buffered    int
+	wroteHeader bool
+	finalized   bool
+	err         error
+}
+
+func (c *chunkedWriter) writeHeader() error {
+	if c.wroteHeader {
+		return c.err
+	}
+	c.wroteHeader = true
+	c.r.Header.SetContentLength(-1)
+	c.err = resp.WriteHeader(&c.r.Header, c.w)
+	return c.err
+}
+
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	if err := c.writeHeader(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		// an empty chunk would end the body
+		return 0, nil
+	}
+	var size [16]byte
+	hex := strconv.AppendInt(size[:0], int64(len(p)), 16)
+	buf, err := c.w.Malloc(len(hex) + 2 + len(p) + 2)
+	if err != nil {
+		c.err = err
+		return 0, err
+	}
+	n := copy(buf, hex)
+	n += copy(buf[n:], "\r\n")
+	n += copy(buf[n:], p)
+	copy(buf[n:], "\r\n")
+	if c.buffered += len(buf); c.buffered >= streamFlushSize {
+		if err := c.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (c *chunkedWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.buffered = 0
+	if err := c.w.Flush(); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+// Finalize writes the last chunk and the trailers, it is called by hertz once the
+// handler returned.
+func (c *chunkedWriter) Finalize() error {
+	if c.finalized {
+		return c.err
+	}
+	c.finalized = true
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	if err := ext.WriteChunk(c.w, nil, false); err != nil {
+		c.err = err
+		return err
+	}
+	if c.err = ext.WriteTrailer(c.r.Header.Trailer(), c.w); c.err != nil {
+		return c.err
+	}
+	c.err = c.w.Flush()
+	return c.err
+}
+
+// copyResponseHeader copies the net/http headers to the hertz response headers,
+// but Content-Length which is up to hertz, and the trailers.
+func copyResponseHeader(dst *protocol.ResponseHeader, src http.Header) {
+	for k, v := range src {
+		if k == consts.HeaderContentLength || k == consts.HeaderTrailer || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		for _, vv := range v {
+			if k == consts.HeaderSetCookie {
+				cookie := protocol.AcquireCookie()
+				_ = cookie.Parse(vv)
+				dst.SetCookie(cookie)
+				protocol.ReleaseCookie(cookie)
+				continue
+			}
+			dst.Add(k, vv)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var errHijackAfterFlush = errors.New("adaptor: Hijack called after the response was flushed")
+
+// responseWriter is the http.ResponseWriter given to the net/http handler.
+//
+// The body is held in the hertz response until the handler flushes it, or as it is
+// written with WithStreaming, the response is then written to the connection as chunks.
+// It also implements http.Flusher, http.Hijacker, io.ReaderFrom, and the methods
+// looked for by http.ResponseController.
+type responseWriter struct {
+	c           *app.RequestContext
+	header      http.Header
+	streaming   bool
+	wroteHeader bool
+	hijacked    *hijackedConn
+	chunked     *chunkedWriter
+}
+
+func newResponseWriter(c *app.RequestContext, streaming bool) *responseWriter {
+	w := &responseWriter{header: make(http.Header)}
+	w.init(c, streaming)
+	return w
+}
+
+// init sets up w to write the response of c.
+func (w *responseWriter) init(c *app.RequestContext, streaming bool) {
+	w.c = c
+	w.streaming = streaming
+	// headers set by the previous handlers are part of the response too,
+	// move them over to be able to tell whether the handler set Content-Type.
+	c.Response.Header.SetNoDefaultContentType(true)
+	if c.Response.Header.Len() == 0 {
+		return
+	}
+	keys := make([][]byte, 0, c.Response.Header.Len())
+	c.Response.Header.VisitAll(func(k, v []byte) {
+		w.header.Add(string(k), string(v))
+		keys = append(keys, k)
+	})
+	for _, k := range keys {
+		c.Response.Header.DelBytes(k)
+	}
+}
+
+// reset clears w to be reused, keeping the memory of its header.
+func (w *responseWriter) reset() {
+	for k := range w.header {
+		delete(w.header, k)
+	}
+	*w = responseWriter{header: w.header}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader || w.hijacked != nil {
+		return
+	}
+	w.wroteHeader = true
+	copyResponseHeader(&w.c.Response.Header, w.header)
+	if declared := w.header.Values(consts.HeaderTrailer); len(declared) > 0 {
+		w.c.Response.Header.Trailer().SetTrailers([]byte(strings.Join(declared, ","))) // nolint:errcheck
+	}
+	w.c.Response.Header.SetStatusCode(statusCode)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.hijacked != nil {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(consts.StatusOK)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if w.chunked == nil && len(w.c.Response.Header.ContentType()) == 0 {
+		// like net/http, sniff from the first write if the handler did not tell
+		l := 512
+		if len(p) < 512 {
+			l = len(p)
+		}
+		w.c.Response.Header.SetContentType(http.DetectContentType(p[:l]))
+	}
+	if w.chunked == nil && (!w.streaming || w.conn() == nil) {
+		w.c.Response.AppendBody(p)
+		return len(p), nil
+	}
+	return w.writer().Write(p)
+}
+
+// ReadFrom copies src to the response body.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.wroteHeader && w.chunked == nil && !w.streaming && len(w.c.Response.Header.ContentType()) > 0 {
+		// nothing left to do on the first write, read straight into the body
+		return w.c.Response.BodyBuffer().ReadFrom(src)
+	}
+	return io.Copy(writerOnly{w}, src)
+}
+
+// Flush sends the status, headers and the body written so far to the client,
+// the rest of the body is then sent as it is written.
+func (w *responseWriter) Flush() {
+	w.FlushError() // nolint:errcheck
+}
+
+// FlushError is Flush returning the error, as used by http.ResponseController.
+func (w *responseWriter) FlushError() error {
+	if w.hijacked != nil {
+		return http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(consts.StatusOK)
+	}
+	if w.conn() == nil {
+		return nil
+	}
+	return w.writer().Flush()
+}
+
+// Hijack lets the handler take over the connection, e.g. for websockets. The connection
+// is kept from hertz until the handler closes it, which has to be done as with net/http.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn := w.conn()
+	if conn == nil {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.hijacked != nil {
+		return nil, nil, http.ErrHijacked
+	}
+	if w.chunked != nil {
+		return nil, nil, errHijackAfterFlush
+	}
+	w.hijacked = newHijackedConn(conn)
+	// hertz must neither write the response nor read the next request.
+	w.c.Response.HijackWriter(discardWriter{})
+	w.c.Hijack(closeHijackedConn)
+	return w.hijacked, bufio.NewReadWriter(bufio.NewReader(w.hijacked), bufio.NewWriter(w.hijacked)), nil
+}
+
+// SetReadDeadline is used by http.ResponseController. hertz connections only have
+// timeouts, so the deadline applies to each following read.
+func (w *responseWriter) SetReadDeadline(t time.Time) error {
+	conn := w.conn()
+	if conn == nil {
+		return http.ErrNotSupported
+	}
+	return conn.SetReadTimeout(deadlineTimeout(t))
+}
+
+// SetWriteDeadline is used by http.ResponseController. hertz connections only have
+// timeouts, so the deadline applies to each following write.
+func (w *responseWriter) SetWriteDeadline(t time.Time) error {
+	conn := w.conn()
+	if conn == nil {
+		return http.ErrNotSupported
+	}
+	return conn.SetWriteTimeout(deadlineTimeout(t))
+}
+
+// conn returns the connection the response can be written to, if any, it is not
+// with ut.PerformRequest, or when served by NewHTTPHandler.
+func (w *responseWriter) conn() network.Conn {
+	conn := w.c.GetConn()
+	if _, ok := conn.(*httpConn); ok {
+		return nil
+	}
+	return conn
+}
+
+// writer returns the chunked writer hijacking the hertz response, the body
+// written so far is its first chunk.
+func (w *responseWriter) writer() *chunkedWriter {
+	if w.chunked == nil {
+		w.chunked = &chunkedWriter{r: &w.c.Response, w: w.c.GetWriter()}
+		w.c.Response.HijackWriter(w.chunked)
+		if body := w.c.Response.Body(); len(body) > 0 {
+			w.chunked.Write(body) // nolint:errcheck
+			w.c.Response.ResetBody()
+		}
+	}
+	return w.chunked
+}
+
+// finish completes the response once the handler returned.
+func (w *responseWriter) finish() {
+	if w.hijacked != nil {
+		// hertz goes on using the connection once the handler returns,
+		// it may only do so once the hijacked connection is done with.
+		<-w.hijacked.closed
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(consts.StatusOK)
+	}
+
+	trailer := w.c.Response.Header.Trailer()
+	var declared []string
+	trailer.VisitAll(func(k, _ []byte) {
+		declared = append(declared, string(k))
+	})
+	for _, k := range declared {
+		if v := w.header.Values(k); len(v) > 0 {
+			trailer.Set(k, strings.Join(v, ", ")) // nolint:errcheck
+		} else {
+			trailer.Del(k)
+		}
+	}
+	for k, v := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailer.Set(strings.TrimPrefix(k, http.TrailerPrefix), strings.Join(v, ", ")) // nolint:errcheck
+		}
+	}
+	if !trailer.Empty() && w.conn() != nil {
+		// trailers are only sent after a chunked body
+		w.writer()
+	}
+}
+
+// writerOnly hides the io.ReaderFrom of the response writer from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// discardWriter is the hertz response writer of hijacked connections,
+// the response is up to the handler.
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (discardWriter) Flush() error { return nil }
+
+func (discardWriter) Finalize() error { return nil }
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/network"
+)
+
+// socketWaitTimeout is how long the admin server may take to create its Unix socket.
+const socketWaitTimeout = 5 * time.Second
+
+// AdminOption configures the server created by NewAdminServer and AttachAdminServer.
+type AdminOption func(o *adminOptions)
+
+type adminOptions struct {
+	network       string
+	socketMode    os.FileMode
+	serverOptions []config.Option
+	pprofOptions  []Option
+	fgprofOptions []Option
+}
+
+// WithAdminNetwork sets the network of the admin server address, "tcp" by default,
+// "unix" to listen on a Unix domain socket path.
+func WithAdminNetwork(network string) AdminOption {
+	return func(o *adminOptions) {
+		o.network = network
+	}
+}
+
+// WithAdminSocketMode sets the file permissions of the Unix domain socket the admin
+// server listens on, e.g. 0o600 to only let the owner of the process connect.
+// It has no effect unless the network is "unix". The socket is only made reachable at
+// its path once it has these permissions.
+func WithAdminSocketMode(mode os.FileMode) AdminOption {
+	return func(o *adminOptions) {
+		o.socketMode = mode
+	}
+}
+
+// WithAdminServerOptions appends options used to create the admin Hertz server.
+func WithAdminServerOptions(opts ...config.Option) AdminOption {
+	return func(o *adminOptions) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+// WithAdminPprofOptions sets the options the pprof routes are registered with.
+func WithAdminPprofOptions(opts ...Option) AdminOption {
+	return func(o *adminOptions) {
+		o.pprofOptions = append(o.pprofOptions, opts...)
+	}
+}
+
+// WithAdminFgprofOptions sets the options the fgprof routes are registered with.
+func WithAdminFgprofOptions(opts ...Option) AdminOption {
+	return func(o *adminOptions) {
+		o.fgprofOptions = append(o.fgprofOptions, opts...)
+	}
+}
+
+// NewAdminServer returns a lightweight Hertz server listening on addr, e.g. "127.0.0.1:6060",
+// which only serves the pprof and fgprof routes. It does not share the listener nor the
+// middlewares of the business server. It runs on the default transport of Hertz, netpoll
+// where available, which lets the captures stop as soon as their client disconnects.
+//
+// With the "unix" network, addr is the path of the socket: a stale socket file is removed
+// before listening, and the socket file is removed once the server is closed.
+func NewAdminServer(addr string, opts ...AdminOption) *server.Hertz {
+	o := &adminOptions{network: "tcp"}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	serverOptions := append([]config.Option{
+		server.WithHostPorts(addr),
+		server.WithNetwork(o.network),
+		server.WithDisablePrintRoute(true),
+	}, o.serverOptions...)
+	if o.network == "unix" && o.socketMode != 0 {
+		serverOptions = append(serverOptions, config.Option{F: func(opts *config.Options) {
+			newTransporter := opts.TransporterNewer
+			if newTransporter == nil {
+				newTransporter = defaultAdminTransporter
+			}
+			opts.TransporterNewer = func(opts *config.Options) network.Transporter {
+				return &socketTransporter{newTransporter: newTransporter, options: opts, mode: o.socketMode}
+			}
+		}})
+	}
+	h := server.New(serverOptions...)
+
+	fgprofPath := newOptions(DefaultFgprofPrefix, o.fgprofOptions...).prefix
+	RegisterWithOptions(h, append([]Option{WithFgprof(fgprofPath)}, o.pprofOptions...)...)
+	FgprofRegisterWithOptions(h, o.fgprofOptions...)
+	return h
+}
+
+// socketTransporter binds the Unix socket of the admin server in a private directory next
+// to its address, and only moves it to the address once it has the configured mode, so that
+// no other user can connect in between whatever the umask is.
+type socketTransporter struct {
+	newTransporter func(options *config.Options) network.Transporter
+	options        *config.Options
+	mode           os.FileMode
+
+	mu        sync.Mutex
+	transport network.Transporter
+	closed    bool
+}
+
+func (t *socketTransporter) ListenAndServe(onData network.OnData) error {
+	dir, err := ioutil.TempDir(filepath.Dir(t.options.Addr), ".pprof-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	options := *t.options
+	options.Addr = filepath.Join(dir, filepath.Base(t.options.Addr))
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.transport = t.newTransporter(&options)
+	t.mu.Unlock()
+
+	go t.publish(options.Addr)
+	return t.transport.ListenAndServe(onData)
+}
+
+// publish waits for the socket to be created at path, sets its mode and moves it to the
+// address of the server, replacing a stale socket file if any.
+func (t *socketTransporter) publish(path string) {
+	deadline := time.Now().Add(socketWaitTimeout)
+	for {
+		err := os.Chmod(path, t.mode)
+		if err == nil {
+			t.mu.Lock()
+			if !t.closed {
+				err = os.Rename(path, t.options.Addr)
+			}
+			t.mu.Unlock()
+		}
+		if err == nil {
+			return
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			hlog.SystemLogger().Errorf("publish pprof admin socket=%s error: %v", t.options.Addr, err)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (t *socketTransporter) Close() error {
+	return t.stop(func(transport network.Transporter) error { return transport.Close() })
+}
+
+func (t *socketTransporter) Shutdown(ctx context.Context) error {
+	return t.stop(func(transport network.Transporter) error { return transport.Shutdown(ctx) })
+}
+
+func (t *socketTransporter) stop(stop func(transport network.Transporter) error) error {
+	t.mu.Lock()
+	t.closed = true
+	transport := t.transport
+	if err := os.Remove(t.options.Addr); err != nil && !os.IsNotExist(err) {
+		hlog.SystemLogger().Errorf("remove pprof admin socket=%s error: %v", t.options.Addr, err)
+	}
+	t.mu.Unlock()
+	if transport == nil {
+		return nil
+	}
+	return stop(transport)
+}
+
+// AttachAdminServer creates the admin server by NewAdminServer and ties it to the lifecycle
+// of h: it starts serving once h runs, and is closed when h shuts down.
+func AttachAdminServer(h *server.Hertz, addr string, opts ...AdminOption) *server.Hertz {
+	admin := NewAdminServer(addr, opts...)
+
+	var closed int32
+	h.OnRun = append(h.OnRun, func(ctx context.Context) error {
+		go func() {
+			// netpoll panics if it cannot listen, which must not take the process down
+			defer func() {
+				if r := recover(); r != nil {
+					hlog.SystemLogger().Errorf("pprof admin server on address=%s stopped: %v", addr, r)
+				}
+			}()
+			if err := admin.Run(); err != nil && atomic.LoadInt32(&closed) == 0 {
+				hlog.SystemLogger().Errorf("pprof admin server on address=%s stopped: %v", addr, err)
+			}
+		}()
+		return nil
+	})
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		atomic.StoreInt32(&closed, 1)
+		// Shutdown polls the connections every second until they are idle, so close it
+		// right away not to delay the shutdown of h, whether connections are left or not.
+		if err := admin.Close(); err != nil && err != context.DeadlineExceeded {
+			hlog.SystemLogger().Errorf("close pprof admin server on address=%s error: %v", addr, err)
+		}
+	})
+	return admin
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+)
+
+func Test_Attach_Admin_Server(t *testing.T) {
+	h := server.New(server.WithHostPorts("127.0.0.1:10041"))
+	AttachAdminServer(h, "127.0.0.1:10042",
+		WithAdminPprofOptions(WithEndpoints("heap")),
+	)
+	go h.Run()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10042/debug/pprof/heap")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get("http://127.0.0.1:10042/debug/pprof/goroutine")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get("http://127.0.0.1:10041/debug/pprof/heap")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, h.Shutdown(ctx))
+
+	_, err = http.Get("http://127.0.0.1:10042/debug/pprof/heap")
+	assert.NotNil(t, err)
+}
+
+func Test_Admin_Server_Unix_Socket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pprof.sock")
+	// stale socket file left by a previous process
+	assert.Nil(t, ioutil.WriteFile(path, nil, 0o644))
+
+	h := server.New(server.WithHostPorts("127.0.0.1:10043"))
+	AttachAdminServer(h, path, WithAdminNetwork("unix"), WithAdminSocketMode(0o600))
+	go h.Run()
+	// the socket must never be reachable at path with other permissions
+	for deadline := time.Now().Add(time.Millisecond * 500); time.Now().Before(deadline); {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			assert.DeepEqual(t, os.FileMode(0o600), info.Mode().Perm())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, os.ModeSocket, info.Mode()&os.ModeSocket)
+	assert.DeepEqual(t, os.FileMode(0o600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/debug/pprof/heap")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.DeepEqual(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, h.Shutdown(ctx))
+
+	_, err = os.Stat(path)
+	assert.DeepEqual(t, true, os.IsNotExist(err))
+	time.Sleep(time.Millisecond * 100)
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 0, len(entries))
+}
+
+func Test_Admin_Server_Client_Disconnect(t *testing.T) {
+	coordinator := NewCoordinator(0, 0)
+	admin := NewAdminServer("127.0.0.1:10061", WithAdminPprofOptions(WithCoordinator(coordinator)))
+	go admin.Run()
+	defer admin.Close()
+	time.Sleep(time.Millisecond * 500)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:10061/debug/pprof/profile?seconds=30", nil)
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(time.Millisecond * 500)
+		assert.NotNil(t, coordinator.Holder())
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	assert.NotNil(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for coordinator.Holder() != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 50)
+	}
+	assert.Assertf(t, coordinator.Holder() == nil, "profile kept running after the client disconnected")
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// ipAllowlist only lets through the requests coming from allowed networks.
+type ipAllowlist struct {
+	allowed []*net.IPNet
+	// trusted are the proxies whose X-Forwarded-For header is believed.
+	trusted []*net.IPNet
+}
+
+// newIPAllowlist panics if any of the CIDRs or IPs is malformed.
+func newIPAllowlist(allowed, trusted []string) *ipAllowlist {
+	return &ipAllowlist{
+		allowed: mustParseNetworks(allowed),
+		trusted: mustParseNetworks(trusted),
+	}
+}
+
+func mustParseNetworks(cidrs []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				panic(fmt.Sprintf("pprof: invalid IP %q", cidr))
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("pprof: invalid CIDR %q: %v", cidr, err))
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the remote address of the connection, or if the connection comes
+// from a trusted proxy, the right-most X-Forwarded-For hop which is not a trusted proxy.
+func (l *ipAllowlist) clientIP(c *app.RequestContext) net.IP {
+	var ip net.IP
+	switch addr := c.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil
+		}
+		ip = net.ParseIP(host)
+	}
+	if ip == nil || !containsIP(l.trusted, ip) {
+		return ip
+	}
+
+	hops := strings.Split(string(c.Request.Header.Peek("X-Forwarded-For")), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// the header is malformed from here on, trust nothing beyond it
+			return ip
+		}
+		ip = hop
+		if !containsIP(l.trusted, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (l *ipAllowlist) middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ip := l.clientIP(c)
+		if ip == nil || !containsIP(l.allowed, ip) {
+			c.AbortWithStatus(consts.StatusForbidden)
+			return
+		}
+		c.Next(ctx)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/network"
+)
+
+type remoteAddrConn struct {
+	network.Conn
+	addr net.Addr
+}
+
+func (c *remoteAddrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func Test_ipAllowlist_clientIP(t *testing.T) {
+	l := newIPAllowlist([]string{"10.0.0.0/8"}, []string{"192.168.0.1", "172.16.0.0/12"})
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"direct", "10.1.1.1", "", "10.1.1.1"},
+		{"untrusted proxy", "8.8.8.8", "10.1.1.1", "8.8.8.8"},
+		{"trusted proxy", "192.168.0.1", "10.1.1.1", "10.1.1.1"},
+		{"trusted proxies chain", "192.168.0.1", "8.8.8.8, 10.1.1.1, 172.16.0.2", "10.1.1.1"},
+		{"spoofed left-most hop", "192.168.0.1", "10.1.1.1, 8.8.8.8", "8.8.8.8"},
+		{"malformed hop", "192.168.0.1", "10.1.1.1, unknown", "192.168.0.1"},
+		{"trusted proxy without header", "192.168.0.1", "", "192.168.0.1"},
+	}
+	for _, tt := range tests {
+		c := app.NewContext(0)
+		c.SetConn(&remoteAddrConn{addr: &net.TCPAddr{IP: net.ParseIP(tt.remote)}})
+		if tt.xff != "" {
+			c.Request.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := l.clientIP(c); !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("%q. clientIP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_mustParseNetworks(t *testing.T) {
+	networks := mustParseNetworks([]string{"10.0.0.1", "::1", "192.168.0.0/16"})
+	assert.DeepEqual(t, true, containsIP(networks, net.ParseIP("10.0.0.1")))
+	assert.DeepEqual(t, false, containsIP(networks, net.ParseIP("10.0.0.2")))
+	assert.DeepEqual(t, true, containsIP(networks, net.ParseIP("::1")))
+	assert.DeepEqual(t, true, containsIP(networks, net.ParseIP("192.168.3.4")))
+
+	assert.Panic(t, func() { mustParseNetworks([]string{"10.0.0"}) })
+	assert.Panic(t, func() { mustParseNetworks([]string{"10.0.0.0/33"}) })
+}
+
+func Test_Allowed_CIDRs(t *testing.T) {
+	h := server.New()
+	// requests performed by ut come from 0.0.0.0
+	RegisterWithOptions(h, WithAllowedCIDRs("10.0.0.0/8"))
+	FgprofRegisterWithOptions(h, WithAllowedCIDRs("10.0.0.0/8"))
+	RegisterWithOptions(h, WithPrefix("/proxied/pprof"), WithAllowedCIDRs("10.0.0.0/8"), WithTrustedProxies("0.0.0.0"))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/fgprof/", nil)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+
+	// X-Forwarded-For is ignored unless the proxy is trusted
+	header := ut.Header{Key: "X-Forwarded-For", Value: "10.0.0.1"}
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, header)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/proxied/pprof/heap", nil, header)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/proxied/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+}
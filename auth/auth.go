@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package auth provides authentication middlewares for the profiling endpoints.
+//
+// Authenticators can be combined, a request is let through as soon as one of them
+// accepts it:
+//
+//	signer := auth.NewSigner([]byte("secret"))
+//	pprof.RegisterWithOptions(h, pprof.WithMiddleware(auth.New(
+//		auth.Basic("admin", "password"),
+//		auth.Bearer(validateToken),
+//		signer,
+//	)))
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Authenticator decides whether a request may reach the profiling endpoints.
+type Authenticator interface {
+	Authenticate(ctx context.Context, c *app.RequestContext) bool
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary functions as Authenticator.
+type AuthenticatorFunc func(ctx context.Context, c *app.RequestContext) bool
+
+// Authenticate calls f(ctx, c).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, c *app.RequestContext) bool {
+	return f(ctx, c)
+}
+
+// challenger is implemented by authenticators which tell the client how to authenticate
+// through the WWW-Authenticate header.
+type challenger interface {
+	challenge() string
+}
+
+// New returns a middleware which lets a request through if any of authenticators
+// accepts it, otherwise the request is aborted with 401.
+func New(authenticators ...Authenticator) app.HandlerFunc {
+	var challenges []string
+	for _, a := range authenticators {
+		if ch, ok := a.(challenger); ok {
+			challenges = append(challenges, ch.challenge())
+		}
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		for _, a := range authenticators {
+			if a.Authenticate(ctx, c) {
+				c.Next(ctx)
+				return
+			}
+		}
+		for _, ch := range challenges {
+			c.Response.Header.Add(consts.HeaderWWWAuthenticate, ch)
+		}
+		c.AbortWithStatus(consts.StatusUnauthorized)
+	}
+}
+
+type basic struct {
+	username []byte
+	password []byte
+}
+
+// Basic accepts requests carrying the given static basic-auth credentials.
+func Basic(username, password string) Authenticator {
+	return &basic{
+		username: []byte(username),
+		password: []byte(password),
+	}
+}
+
+func (b *basic) Authenticate(ctx context.Context, c *app.RequestContext) bool {
+	payload, ok := cutScheme(string(c.Request.Header.Peek(consts.HeaderAuthorization)), "Basic")
+	if !ok {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return false
+	}
+	// evaluate both to not leak which one mismatched
+	userMatch := subtle.ConstantTimeCompare([]byte(username), b.username)
+	passMatch := subtle.ConstantTimeCompare([]byte(password), b.password)
+	return userMatch&passMatch == 1
+}
+
+func (b *basic) challenge() string {
+	return `Basic realm="pprof", charset="UTF-8"`
+}
+
+type bearer struct {
+	validate func(ctx context.Context, token string) bool
+}
+
+// Bearer accepts requests carrying a bearer token validate reports as valid.
+func Bearer(validate func(ctx context.Context, token string) bool) Authenticator {
+	return &bearer{validate: validate}
+}
+
+func (b *bearer) Authenticate(ctx context.Context, c *app.RequestContext) bool {
+	token, ok := cutScheme(string(c.Request.Header.Peek(consts.HeaderAuthorization)), "Bearer")
+	if !ok || token == "" {
+		return false
+	}
+	return b.validate(ctx, token)
+}
+
+func (b *bearer) challenge() string {
+	return `Bearer realm="pprof"`
+}
+
+// cutScheme returns the credentials of an Authorization header value if it uses scheme.
+func cutScheme(header, scheme string) (string, bool) {
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) || header[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(scheme)+1:]), true
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func newTestServer(authenticators ...Authenticator) *server.Hertz {
+	h := server.New()
+	h.GET("/debug/pprof/heap", New(authenticators...), func(c context.Context, ctx *app.RequestContext) {
+		ctx.String(http.StatusOK, "heap")
+	})
+	return h
+}
+
+func basicHeader(username, password string) ut.Header {
+	return ut.Header{
+		Key:   "Authorization",
+		Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)),
+	}
+}
+
+func Test_Basic(t *testing.T) {
+	h := newTestServer(Basic("admin", "password"))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+	assert.DeepEqual(t, `Basic realm="pprof", charset="UTF-8"`, resp.Header().Get("WWW-Authenticate"))
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, basicHeader("admin", "wrong"))
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, ut.Header{Key: "Authorization", Value: "Basic !!!"})
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, basicHeader("admin", "password"))
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+}
+
+func Test_Bearer(t *testing.T) {
+	h := newTestServer(Bearer(func(ctx context.Context, token string) bool {
+		return token == "token"
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic token", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"invalid token", "Bearer other", http.StatusUnauthorized},
+		{"valid token", "Bearer token", http.StatusOK},
+		{"case insensitive scheme", "bearer token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		var headers []ut.Header
+		if tt.header != "" {
+			headers = append(headers, ut.Header{Key: "Authorization", Value: tt.header})
+		}
+		resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, headers...)
+		if resp.Code != tt.want {
+			t.Errorf("%q. status = %v, want %v", tt.name, resp.Code, tt.want)
+		}
+	}
+}
+
+func Test_New_Any(t *testing.T) {
+	h := newTestServer(
+		Basic("admin", "password"),
+		AuthenticatorFunc(func(ctx context.Context, c *app.RequestContext) bool {
+			return string(c.Request.Header.Peek("X-Internal")) == "true"
+		}),
+	)
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, ut.Header{Key: "X-Internal", Value: "true"})
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, basicHeader("admin", "password"))
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+}
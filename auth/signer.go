@@ -0,0 +1,138 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/hertz-contrib/pprof/internal/strutil"
+)
+
+const (
+	// ExpiresParam is the query parameter carrying the unix time a signed url expires at.
+	ExpiresParam = "expires"
+	// SignatureParam is the query parameter carrying the signature of a signed url.
+	SignatureParam = "signature"
+)
+
+// Signer signs urls with HMAC-SHA256 so that they can be shared for a limited time,
+// e.g. a one-off link to a 30 seconds CPU profile. It is also the Authenticator
+// accepting the urls it signed.
+//
+// The signature covers the path and every query parameter, so none of them can be
+// changed without invalidating the url.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewSigner returns a Signer using secret as HMAC key.
+func NewSigner(secret []byte) *Signer {
+	return &Signer{
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// Sign returns rawURL with the expires and signature query parameters appended,
+// valid for ttl. rawURL is usually a path with query, e.g. "/debug/pprof/profile?seconds=30",
+// scheme and host are kept as is but not signed.
+func (s *Signer) Sign(rawURL string, ttl time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Del(SignatureParam)
+	query.Set(ExpiresParam, strconv.FormatInt(s.now().Add(ttl).Unix(), 10))
+	query.Set(SignatureParam, s.signature(u.Path, query))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Authenticate accepts requests whose url was signed by s and has not expired yet.
+func (s *Signer) Authenticate(ctx context.Context, c *app.RequestContext) bool {
+	query, err := url.ParseQuery(string(c.Request.URI().QueryString()))
+	if err != nil {
+		return false
+	}
+	signature := query.Get(SignatureParam)
+	expires, err := strconv.ParseInt(query.Get(ExpiresParam), 10, 64)
+	if signature == "" || err != nil || s.now().Unix() > expires {
+		return false
+	}
+	query.Del(SignatureParam)
+	expected := s.signature(string(c.Request.URI().Path()), query)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// SignValue returns value signed for ttl, to be checked with VerifyValue, e.g. the value of
+// a request header: "cpu;expires=1672671845;signature=...". value must not contain ';'.
+func (s *Signer) SignValue(value string, ttl time.Duration) string {
+	expires := strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+	return value + ";" + ExpiresParam + "=" + expires + ";" + SignatureParam + "=" + s.valueSignature(value, expires)
+}
+
+// VerifyValue returns the value signed by SignValue, if the signature is valid and has not expired yet.
+func (s *Signer) VerifyValue(signed string) (string, bool) {
+	value, rest, _ := strings.Cut(signed, ";")
+	expires, signature, _ := strings.Cut(rest, ";")
+	expires, ok1 := strutil.CutPrefix(expires, ExpiresParam+"=")
+	signature, ok2 := strutil.CutPrefix(signature, SignatureParam+"=")
+	if !ok1 || !ok2 {
+		return "", false
+	}
+	t, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > t {
+		return "", false
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.valueSignature(value, expires))) {
+		return "", false
+	}
+	return value, true
+}
+
+// valueSignature returns the hex encoded HMAC of a value signed by SignValue. It starts
+// with a NUL byte, which paths do not, to never be the same as the signature of an url.
+func (s *Signer) valueSignature(value, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	mac.Write([]byte{';'})
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signature returns the hex encoded HMAC of path and query, query must not contain
+// the signature itself. url.Values.Encode sorts by key, so the order parameters
+// appear in the url does not matter.
+func (s *Signer) signature(path string, query url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func Test_Signer(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signer := NewSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+	h := newTestServer(signer)
+
+	signed, err := signer.Sign("/debug/pprof/heap?gc=1", time.Minute)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, true, strings.Contains(signed, "expires=1700000060"))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, signed, nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap?gc=1", nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	// tampered query
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, strings.Replace(signed, "gc=1", "gc=0", 1), nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	// signed by another secret
+	other := NewSigner([]byte("other"))
+	other.now = signer.now
+	forged, err := other.Sign("/debug/pprof/heap?gc=1", time.Minute)
+	assert.Nil(t, err)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, forged, nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	// expired
+	now = now.Add(2 * time.Minute)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, signed, nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+}
+
+func Test_Signer_Resign(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	signed, err := signer.Sign("/debug/pprof/heap", time.Minute)
+	assert.Nil(t, err)
+	resigned, err := signer.Sign(signed, time.Minute)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 1, strings.Count(resigned, SignatureParam+"="))
+}
+
+func Test_Signer_Value(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signer := NewSigner([]byte("secret"))
+	signer.now = func() time.Time { return now }
+
+	signed := signer.SignValue("cpu", time.Minute)
+	assert.DeepEqual(t, true, strings.HasPrefix(signed, "cpu;expires=1700000060;signature="))
+	value, ok := signer.VerifyValue(signed)
+	assert.DeepEqual(t, true, ok)
+	assert.DeepEqual(t, "cpu", value)
+
+	tests := []struct {
+		name   string
+		signed string
+	}{
+		{"not signed", "cpu"},
+		{"tampered value", strings.Replace(signed, "cpu", "fgprof", 1)},
+		{"tampered expiry", strings.Replace(signed, "1700000060", "1800000000", 1)},
+		{"missing signature", "cpu;expires=1700000060"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		if got, ok := signer.VerifyValue(tt.signed); ok {
+			t.Errorf("%q. VerifyValue() = %v, want rejected", tt.name, got)
+		}
+	}
+
+	// an url signature is not a value signature
+	url, err := signer.Sign("cpu", time.Minute)
+	assert.Nil(t, err)
+	_, ok = signer.VerifyValue(strings.Replace(strings.Replace(url, "?", ";", 1), "&", ";", 1))
+	assert.DeepEqual(t, false, ok)
+
+	// expired
+	now = now.Add(2 * time.Minute)
+	_, ok = signer.VerifyValue(signed)
+	assert.DeepEqual(t, false, ok)
+}
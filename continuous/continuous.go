@@ -0,0 +1,267 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package continuous captures profiles in the background on a schedule and keeps them in
+// a store.Store, so that the profiles from before an incident can be looked at afterwards
+// through the routes registered with pprof.WithStore:
+//
+//	profiles, err := store.NewDiskStore("/var/lib/app/profiles", store.WithMaxAge(24*time.Hour))
+//	_, err = continuous.Attach(h, profiles, continuous.WithInterval(time.Minute))
+//	pprof.RegisterWithOptions(h, pprof.WithStore(profiles))
+//
+// A Watcher captures them when the metrics of the process cross a threshold or spike instead.
+package continuous
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/felixge/fgprof"
+
+	hpprof "github.com/hertz-contrib/pprof"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// Source is the source of the profiles captured by a Profiler.
+const Source = "continuous"
+
+// The types of profiles a Profiler captures by default, see WithProfiles.
+const (
+	CPU       = "cpu"
+	Heap      = "heap"
+	Goroutine = "goroutine"
+	Mutex     = "mutex"
+	Block     = "block"
+
+	// Fgprof is the type of the fgprof profiles, see WithFgprof.
+	Fgprof = "fgprof"
+)
+
+// Option configures a Profiler.
+type Option func(o *options)
+
+type options struct {
+	interval       time.Duration
+	cpuDuration    time.Duration
+	fgprofDuration time.Duration
+	types          []string
+	coordinator    *hpprof.Coordinator
+}
+
+// WithInterval sets how often the profiles are captured, every minute by default.
+// It must be positive.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// WithCPUDuration sets how long the CPU profile of each round lasts, 10 seconds by default.
+func WithCPUDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.cpuDuration = d
+	}
+}
+
+// WithProfiles sets the types of profiles captured each round, among CPU, Heap, Goroutine,
+// Mutex, Block, and the other profiles of runtime/pprof, all five by default.
+// The mutex and block profiles are empty unless enabled by runtime.SetMutexProfileFraction
+// and runtime.SetBlockProfileRate.
+func WithProfiles(types ...string) Option {
+	return func(o *options) {
+		o.types = types
+	}
+}
+
+// WithFgprof also captures a fgprof profile lasting d each round, after the CPU one.
+func WithFgprof(d time.Duration) Option {
+	return func(o *options) {
+		o.fgprofDuration = d
+	}
+}
+
+// WithCoordinator sets the Coordinator the CPU and fgprof captures take turns with the
+// on-demand ones on, pprof.DefaultCoordinator() by default. A capture is skipped for
+// the round if another one is running. Pass nil to not coordinate captures at all.
+func WithCoordinator(co *hpprof.Coordinator) Option {
+	return func(o *options) {
+		o.coordinator = co
+	}
+}
+
+// Profiler captures profiles on a schedule and puts them in a store.Store.
+type Profiler struct {
+	store store.Store
+	o     *options
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a Profiler putting the profiles in s, started by Start.
+// It fails if the interval is not positive, or a type of profile given by WithProfiles
+// is unknown.
+func New(s store.Store, opts ...Option) (*Profiler, error) {
+	o := &options{
+		interval:    time.Minute,
+		cpuDuration: 10 * time.Second,
+		types:       []string{CPU, Heap, Goroutine, Mutex, Block},
+		coordinator: hpprof.DefaultCoordinator(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.interval <= 0 {
+		return nil, fmt.Errorf("continuous: interval %v is not positive", o.interval)
+	}
+	for _, typ := range o.types {
+		if typ != CPU && pprof.Lookup(typ) == nil {
+			return nil, fmt.Errorf("continuous: unknown profile %q", typ)
+		}
+	}
+	return &Profiler{store: s, o: o}, nil
+}
+
+// Attach creates a Profiler by New and ties it to the lifecycle of h: it starts once h
+// runs, and is stopped when h shuts down.
+func Attach(h *server.Hertz, s store.Store, opts ...Option) (*Profiler, error) {
+	p, err := New(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.OnRun = append(h.OnRun, func(ctx context.Context) error {
+		p.Start()
+		return nil
+	})
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		p.Stop()
+	})
+	return p, nil
+}
+
+// Start starts capturing the profiles in the background, the first round right away.
+// It does nothing if the Profiler is already running.
+func (p *Profiler) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel, p.done = cancel, make(chan struct{})
+	go p.run(ctx, p.done)
+}
+
+// Stop stops capturing the profiles, cutting a running capture short, and waits for the
+// profiles being captured to be stored.
+func (p *Profiler) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+	p.cancel, p.done = nil, nil
+}
+
+func (p *Profiler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.o.interval)
+	defer ticker.Stop()
+	for {
+		p.round(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// round captures each type of profile once.
+func (p *Profiler) round(ctx context.Context) {
+	types := p.o.types
+	if p.o.fgprofDuration > 0 {
+		types = append(types[:len(types):len(types)], Fgprof)
+	}
+	for _, typ := range types {
+		if ctx.Err() != nil {
+			return
+		}
+		d := p.o.cpuDuration
+		if typ == Fgprof {
+			d = p.o.fgprofDuration
+		}
+		profile, data, err := capture(ctx, p.o.coordinator, Source, typ, d)
+		if err != nil {
+			hlog.SystemLogger().Warnf("continuous %s profile error: %v", typ, err)
+			continue
+		}
+		if _, err := p.store.Put(profile, data); err != nil {
+			hlog.SystemLogger().Errorf("store continuous %s profile error: %v", typ, err)
+		}
+	}
+}
+
+// capture takes a profile of the given type on behalf of source: CPU and Fgprof ones last d,
+// or until ctx is done, and take turns on co unless it is nil, the other ones are a snapshot.
+func capture(ctx context.Context, co *hpprof.Coordinator, source, typ string, d time.Duration) (store.Profile, []byte, error) {
+	var buf bytes.Buffer
+	profile := store.Profile{Type: typ, Source: source, StartedAt: time.Now()}
+	if typ != CPU && typ != Fgprof {
+		if err := pprof.Lookup(typ).WriteTo(&buf, 0); err != nil {
+			return store.Profile{}, nil, err
+		}
+		return profile, buf.Bytes(), nil
+	}
+
+	if co != nil {
+		release, err := co.Acquire(ctx, source+" "+typ, d)
+		if err != nil {
+			return store.Profile{}, nil, err
+		}
+		defer release()
+	}
+
+	stop := func() {}
+	if typ == CPU {
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return store.Profile{}, nil, err
+		}
+		stop = pprof.StopCPUProfile
+	} else {
+		stopFgprof := fgprof.Start(&buf, fgprof.FormatPprof)
+		stop = func() { stopFgprof() } // nolint:errcheck
+	}
+	profile.StartedAt = time.Now()
+	timer := time.NewTimer(d)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+	stop()
+	profile.Duration = time.Since(profile.StartedAt)
+	return profile, buf.Bytes(), nil
+}
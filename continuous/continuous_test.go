@@ -0,0 +1,124 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package continuous
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/google/pprof/profile"
+
+	hpprof "github.com/hertz-contrib/pprof"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+func TestProfiler(t *testing.T) {
+	s := store.NewMemoryStore(100)
+	p, err := New(s, WithInterval(300*time.Millisecond), WithCPUDuration(100*time.Millisecond),
+		WithFgprof(100*time.Millisecond), WithCoordinator(hpprof.NewCoordinator(0, 0)))
+	assert.Nil(t, err)
+	p.Start()
+	p.Start()
+	time.Sleep(500 * time.Millisecond)
+	p.Stop()
+	p.Stop()
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	count := map[string]int{}
+	for _, stored := range list {
+		count[stored.Type]++
+		assert.DeepEqual(t, Source, stored.Source)
+
+		_, data, err := s.Get(stored.ID)
+		assert.Nil(t, err)
+		_, err = profile.ParseData(data)
+		assert.Nil(t, err)
+	}
+	// two rounds, the first one right away
+	assert.DeepEqual(t, map[string]int{"cpu": 2, "heap": 2, "goroutine": 2, "mutex": 2, "block": 2, "fgprof": 2}, count)
+
+	// nothing is captured once stopped
+	time.Sleep(400 * time.Millisecond)
+	after, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, len(list), len(after))
+
+	_, err = New(s, WithProfiles("unknown"))
+	assert.NotNil(t, err)
+	_, err = New(s, WithInterval(0))
+	assert.NotNil(t, err)
+	_, err = New(s, WithInterval(-time.Second))
+	assert.NotNil(t, err)
+}
+
+func TestProfilerWithoutCoordinator(t *testing.T) {
+	s := store.NewMemoryStore(100)
+	p, err := New(s, WithProfiles(CPU), WithCPUDuration(50*time.Millisecond), WithCoordinator(nil))
+	assert.Nil(t, err)
+	p.Start()
+	time.Sleep(100 * time.Millisecond)
+	p.Stop()
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 1, len(list))
+	assert.DeepEqual(t, CPU, list[0].Type)
+}
+
+func TestProfilerBusy(t *testing.T) {
+	co := hpprof.NewCoordinator(0, 0)
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+	defer release()
+
+	// the CPU profile is skipped while another capture is running
+	s := store.NewMemoryStore(100)
+	p, err := New(s, WithProfiles(CPU, Heap), WithCoordinator(co))
+	assert.Nil(t, err)
+	p.Start()
+	time.Sleep(100 * time.Millisecond)
+	p.Stop()
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 1, len(list))
+	assert.DeepEqual(t, Heap, list[0].Type)
+}
+
+func TestProfilerDeltaBaselines(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	p, err := New(s, WithProfiles("allocs", Block, Mutex))
+	assert.Nil(t, err)
+	p.Start()
+	time.Sleep(100 * time.Millisecond)
+	p.Stop()
+
+	// the snapshots are the baselines of the delta profiles
+	h := server.New()
+	hpprof.RegisterWithOptions(h, hpprof.WithStore(s))
+	for _, name := range []string{"allocs", "heap", "block", "mutex"} {
+		w := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/"+name+"?window=5ms", nil)
+		assert.DeepEqual(t, http.StatusOK, w.Code)
+		assert.True(t, w.Header().Get(hpprof.ProfileBaselineHeader) != "")
+	}
+}
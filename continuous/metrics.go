@@ -0,0 +1,140 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package continuous
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"time"
+)
+
+// Metric is a process metric sampled by a Watcher.
+type Metric string
+
+const (
+	// CPUPercent is the CPU usage of the process since the previous sample, in percent of
+	// one CPU, e.g. 150 for one and a half. It is read from /proc/self/stat, Linux only.
+	CPUPercent Metric = "cpu_percent"
+	// RSS is the resident set size of the process in bytes, read from /proc/self/stat, Linux only.
+	RSS Metric = "rss"
+	// Goroutines is the number of goroutines.
+	Goroutines Metric = "goroutines"
+	// GCPause is the longest stop-the-world GC pause since the previous sample, in seconds.
+	GCPause Metric = "gc_pause"
+	// HeapInUse is the memory occupied by live and not yet swept heap objects, in bytes.
+	HeapInUse Metric = "heap_inuse"
+)
+
+const (
+	gcPausesMetric  = "/gc/pauses:seconds"
+	heapInUseMetric = "/memory/classes/heap/objects:bytes"
+
+	// clockTicks is the USER_HZ unit of the CPU times of /proc, 100 on every Linux
+	// architecture Go supports.
+	clockTicks = 100
+)
+
+// sampler samples the metrics, keeping what it needs from one sample to the next.
+type sampler struct {
+	samples []metrics.Sample
+
+	lastCPU    float64
+	lastSample time.Time
+	lastPauses []uint64
+}
+
+func newSampler() *sampler {
+	return &sampler{
+		samples: []metrics.Sample{{Name: gcPausesMetric}, {Name: heapInUseMetric}},
+	}
+}
+
+// sample returns the current value of the metrics, the ones which are not available
+// on this platform, or not yet, are missing.
+func (s *sampler) sample() map[Metric]float64 {
+	now := time.Now()
+	values := map[Metric]float64{
+		Goroutines: float64(runtime.NumGoroutine()),
+	}
+
+	if cpu, rss, err := readProcStat(); err == nil {
+		values[RSS] = float64(rss)
+		if !s.lastSample.IsZero() {
+			values[CPUPercent] = (cpu - s.lastCPU) / now.Sub(s.lastSample).Seconds() * 100
+		}
+		s.lastCPU, s.lastSample = cpu, now
+	}
+
+	metrics.Read(s.samples)
+	if v := s.samples[0].Value; v.Kind() == metrics.KindFloat64Histogram {
+		h := v.Float64Histogram()
+		if s.lastPauses != nil {
+			values[GCPause] = 0
+			for i := len(h.Counts) - 1; i >= 0; i-- {
+				if h.Counts[i] > s.lastPauses[i] {
+					values[GCPause] = bucketValue(h.Buckets, i)
+					break
+				}
+			}
+		}
+		s.lastPauses = append(s.lastPauses[:0], h.Counts...)
+	}
+	if v := s.samples[1].Value; v.Kind() == metrics.KindUint64 {
+		values[HeapInUse] = float64(v.Uint64())
+	}
+	return values
+}
+
+// bucketValue returns the upper bound of the bucket i of a histogram, or its lower
+// bound for the last, unbounded, bucket.
+func bucketValue(buckets []float64, i int) float64 {
+	if math.IsInf(buckets[i+1], 1) {
+		return buckets[i]
+	}
+	return buckets[i+1]
+}
+
+// readProcStat returns the CPU time in seconds and the resident set size in bytes of the
+// process, from /proc/self/stat.
+func readProcStat() (cpu float64, rss int64, err error) {
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	// the command name may contain spaces, the fields start after it, at the 3rd one.
+	fields := bytes.Fields(b[bytes.LastIndexByte(b, ')')+1:])
+	if len(fields) < 22 {
+		return 0, 0, strconv.ErrSyntax
+	}
+	utime, err := strconv.ParseUint(string(fields[11]), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	stime, err := strconv.ParseUint(string(fields[12]), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	pages, err := strconv.ParseInt(string(fields[21]), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(utime+stime) / clockTicks, pages * int64(os.Getpagesize()), nil
+}
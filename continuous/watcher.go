@@ -0,0 +1,320 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package continuous
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	hpprof "github.com/hertz-contrib/pprof"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// WatcherSource is the source of the profiles captured by a Watcher.
+const WatcherSource = "watcher"
+
+const (
+	// spikeWarmup is the number of samples of a metric needed before its spikes are detected.
+	spikeWarmup = 3
+	// spikeSmoothing is the weight of a sample in the moving average of its metric.
+	spikeSmoothing = 0.2
+)
+
+// Rule tells a Watcher when to capture which profile.
+type Rule struct {
+	// Metric is the metric watched by the rule.
+	Metric Metric
+	// Threshold triggers a capture once the metric reaches it, 0 means no threshold.
+	Threshold float64
+	// Spike triggers a capture once the metric reaches Spike times its moving average,
+	// e.g. 2 when it doubles, 0 means no spike detection.
+	Spike float64
+	// Profile is the type of profile captured, CPU for CPUPercent, Heap for RSS and
+	// HeapInUse, Goroutine for Goroutines and "allocs" for GCPause by default.
+	Profile string
+	// Duration is how long a CPU or Fgprof capture lasts, 10 seconds by default.
+	Duration time.Duration
+}
+
+var defaultRuleProfiles = map[Metric]string{
+	CPUPercent: CPU,
+	RSS:        Heap,
+	HeapInUse:  Heap,
+	Goroutines: Goroutine,
+	GCPause:    "allocs",
+}
+
+// WatcherOption configures a Watcher.
+type WatcherOption func(o *watcherOptions)
+
+type watcherOptions struct {
+	rules       []Rule
+	interval    time.Duration
+	cooldown    time.Duration
+	maxPerHour  int
+	coordinator *hpprof.Coordinator
+}
+
+// WithRule adds a rule to the Watcher.
+func WithRule(r Rule) WatcherOption {
+	return func(o *watcherOptions) {
+		o.rules = append(o.rules, r)
+	}
+}
+
+// WithSampleInterval sets how often the metrics are sampled, every 5 seconds by default.
+func WithSampleInterval(d time.Duration) WatcherOption {
+	return func(o *watcherOptions) {
+		o.interval = d
+	}
+}
+
+// WithCooldown sets how long a rule stays quiet after it triggered a capture, 5 minutes by default.
+// A capture which failed, e.g. because another one was running, does not start the cooldown.
+func WithCooldown(d time.Duration) WatcherOption {
+	return func(o *watcherOptions) {
+		o.cooldown = d
+	}
+}
+
+// WithMaxCapturesPerHour caps the captures of all the rules over the last hour, 6 by default.
+// Only the profiles actually stored count.
+func WithMaxCapturesPerHour(n int) WatcherOption {
+	return func(o *watcherOptions) {
+		o.maxPerHour = n
+	}
+}
+
+// WithWatcherCoordinator sets the Coordinator the CPU and fgprof captures take turns with
+// the other ones on, pprof.DefaultCoordinator() by default.
+func WithWatcherCoordinator(co *hpprof.Coordinator) WatcherOption {
+	return func(o *watcherOptions) {
+		o.coordinator = co
+	}
+}
+
+// Watcher samples the metrics of the process and captures a profile when a rule triggers,
+// so that the profile of a spike is taken during the spike itself.
+type Watcher struct {
+	store  store.Store
+	o      *watcherOptions
+	sample func() map[Metric]float64
+
+	// averages are the exponential moving averages of the metrics, samples their number
+	// of samples so far.
+	averages map[Metric]float64
+	samples  map[Metric]int
+	// lastCaptures are the times each rule last triggered a stored profile, captures
+	// the times of the stored profiles of the last hour.
+	lastCaptures []time.Time
+	captures     []time.Time
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWatcher returns a Watcher putting the profiles in s, started by Start.
+// It fails if the sample interval or the hourly cap is not positive, or a rule has an
+// unknown metric, or neither a threshold nor a spike.
+func NewWatcher(s store.Store, opts ...WatcherOption) (*Watcher, error) {
+	o := &watcherOptions{
+		interval:    5 * time.Second,
+		cooldown:    5 * time.Minute,
+		maxPerHour:  6,
+		coordinator: hpprof.DefaultCoordinator(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.interval <= 0 {
+		return nil, fmt.Errorf("continuous: sample interval %v is not positive", o.interval)
+	}
+	if o.maxPerHour <= 0 {
+		return nil, fmt.Errorf("continuous: max captures per hour %d is not positive", o.maxPerHour)
+	}
+	for i, r := range o.rules {
+		if _, ok := defaultRuleProfiles[r.Metric]; !ok {
+			return nil, fmt.Errorf("continuous: unknown metric %q", r.Metric)
+		}
+		if r.Threshold <= 0 && r.Spike <= 0 {
+			return nil, fmt.Errorf("continuous: rule of metric %q has neither a threshold nor a spike", r.Metric)
+		}
+		if r.Profile == "" {
+			o.rules[i].Profile = defaultRuleProfiles[r.Metric]
+		}
+		if r.Duration <= 0 {
+			o.rules[i].Duration = 10 * time.Second
+		}
+	}
+
+	return &Watcher{
+		store:        s,
+		o:            o,
+		sample:       newSampler().sample,
+		averages:     map[Metric]float64{},
+		samples:      map[Metric]int{},
+		lastCaptures: make([]time.Time, len(o.rules)),
+	}, nil
+}
+
+// AttachWatcher creates a Watcher by NewWatcher and ties it to the lifecycle of h: it starts
+// once h runs, and is stopped when h shuts down.
+func AttachWatcher(h *server.Hertz, s store.Store, opts ...WatcherOption) (*Watcher, error) {
+	w, err := NewWatcher(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.OnRun = append(h.OnRun, func(ctx context.Context) error {
+		w.Start()
+		return nil
+	})
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		w.Stop()
+	})
+	return w, nil
+}
+
+// Start starts watching the metrics in the background.
+// It does nothing if the Watcher is already running.
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel, w.done = cancel, make(chan struct{})
+	go w.run(ctx, w.done)
+}
+
+// Stop stops watching the metrics, cutting a running capture short, and waits for the
+// profile being captured to be stored.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+	w.cancel, w.done = nil, nil
+}
+
+func (w *Watcher) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.check(ctx, time.Now())
+	}
+}
+
+// check samples the metrics and captures the profiles of the rules they trigger.
+func (w *Watcher) check(ctx context.Context, now time.Time) {
+	values := w.sample()
+	for _, t := range w.triggered(now, values) {
+		if ctx.Err() != nil {
+			return
+		}
+		profile, data, err := capture(ctx, w.o.coordinator, WatcherSource, t.rule.Profile, t.rule.Duration)
+		if err != nil {
+			hlog.SystemLogger().Warnf("watcher %s profile error: %v", t.rule.Profile, err)
+			continue
+		}
+		profile.Labels = map[string]string{
+			"metric":  string(t.rule.Metric),
+			"value":   strconv.FormatFloat(t.value, 'g', -1, 64),
+			"trigger": t.reason,
+		}
+		if _, err := w.store.Put(profile, data); err != nil {
+			hlog.SystemLogger().Errorf("store watcher %s profile error: %v", t.rule.Profile, err)
+			continue
+		}
+		w.record(t.index, now)
+	}
+}
+
+// trigger is the rule at index triggered by the value of its metric.
+type trigger struct {
+	index  int
+	rule   Rule
+	value  float64
+	reason string
+}
+
+// triggered returns the rules triggered by values sampled at now, within the cooldowns and
+// the hourly cap, and updates the moving averages. The captures of the triggers only count
+// toward the cooldowns and the cap once recorded.
+func (w *Watcher) triggered(now time.Time, values map[Metric]float64) []trigger {
+	var triggers []trigger
+	for i, r := range w.o.rules {
+		value, ok := values[r.Metric]
+		if !ok {
+			continue
+		}
+		reason := ""
+		switch {
+		case r.Threshold > 0 && value >= r.Threshold:
+			reason = "threshold"
+		case r.Spike > 0 && w.samples[r.Metric] >= spikeWarmup && w.averages[r.Metric] > 0 &&
+			value >= r.Spike*w.averages[r.Metric]:
+			reason = "spike"
+		}
+		if reason == "" || now.Sub(w.lastCaptures[i]) < w.o.cooldown {
+			continue
+		}
+
+		for len(w.captures) > 0 && now.Sub(w.captures[0]) >= time.Hour {
+			w.captures = w.captures[1:]
+		}
+		if len(w.captures)+len(triggers) >= w.o.maxPerHour {
+			hlog.SystemLogger().Warnf("watcher %s=%g capture skipped, %d captures in the last hour",
+				r.Metric, value, len(w.captures))
+			continue
+		}
+		triggers = append(triggers, trigger{i, r, value, reason})
+	}
+
+	for metric, value := range values {
+		if w.samples[metric] == 0 {
+			w.averages[metric] = value
+		} else {
+			w.averages[metric] += spikeSmoothing * (value - w.averages[metric])
+		}
+		w.samples[metric]++
+	}
+	return triggers
+}
+
+// record starts the cooldown of the rule at index, and counts a capture toward the cap,
+// once the profile it triggered at now is stored.
+func (w *Watcher) record(index int, now time.Time) {
+	w.lastCaptures[index] = now
+	w.captures = append(w.captures, now)
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package continuous
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+
+	hpprof "github.com/hertz-contrib/pprof"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+func TestSampler(t *testing.T) {
+	s := newSampler()
+	values := s.sample()
+	assert.True(t, values[Goroutines] >= 1)
+	assert.True(t, values[HeapInUse] > 0)
+	_, ok := values[GCPause]
+	assert.False(t, ok)
+
+	runtime.GC()
+	values = s.sample()
+	assert.True(t, values[GCPause] > 0)
+	if runtime.GOOS == "linux" {
+		assert.True(t, values[RSS] > 0)
+		assert.True(t, values[CPUPercent] >= 0)
+	}
+}
+
+func TestWatcherTriggered(t *testing.T) {
+	w, err := NewWatcher(store.NewMemoryStore(10),
+		WithRule(Rule{Metric: Goroutines, Threshold: 1000}),
+		WithRule(Rule{Metric: HeapInUse, Spike: 2}),
+		WithCooldown(time.Minute),
+		WithMaxCapturesPerHour(3),
+	)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, Goroutine, w.o.rules[0].Profile)
+	assert.DeepEqual(t, Heap, w.o.rules[1].Profile)
+
+	now := time.Now()
+	// every capture succeeds
+	at := func(d time.Duration, goroutines, heap float64) []trigger {
+		triggers := w.triggered(now.Add(d), map[Metric]float64{Goroutines: goroutines, HeapInUse: heap})
+		for _, t := range triggers {
+			w.record(t.index, now.Add(d))
+		}
+		return triggers
+	}
+
+	// spikes are not detected until the average is known
+	assert.DeepEqual(t, 0, len(at(0, 10, 100)))
+	assert.DeepEqual(t, 0, len(at(time.Second, 10, 300)))
+	assert.DeepEqual(t, 0, len(at(2*time.Second, 10, 100)))
+
+	triggers := at(3*time.Second, 1000, 400)
+	assert.DeepEqual(t, 2, len(triggers))
+	assert.DeepEqual(t, "threshold", triggers[0].reason)
+	assert.DeepEqual(t, float64(1000), triggers[0].value)
+	assert.DeepEqual(t, "spike", triggers[1].reason)
+
+	// the rules cool down
+	assert.DeepEqual(t, 0, len(at(30*time.Second, 2000, 100)))
+	assert.DeepEqual(t, 1, len(at(2*time.Minute, 2000, 100)))
+
+	// at most 3 captures per hour
+	assert.DeepEqual(t, 0, len(at(4*time.Minute, 2000, 100)))
+	assert.DeepEqual(t, 1, len(at(time.Hour+4*time.Second, 2000, 100)))
+
+}
+
+func TestNewWatcherInvalid(t *testing.T) {
+	for _, opts := range [][]WatcherOption{
+		{WithRule(Rule{Metric: "unknown", Threshold: 1})},
+		{WithRule(Rule{Metric: RSS})},
+		{WithSampleInterval(0)},
+		{WithSampleInterval(-time.Second)},
+		{WithMaxCapturesPerHour(0)},
+	} {
+		w, err := NewWatcher(store.NewMemoryStore(10), opts...)
+		assert.NotNil(t, err)
+		assert.Nil(t, w)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	w, err := NewWatcher(s,
+		WithRule(Rule{Metric: Goroutines, Threshold: 1}),
+		WithRule(Rule{Metric: CPUPercent, Threshold: 1, Duration: 100 * time.Millisecond}),
+		WithSampleInterval(50*time.Millisecond),
+		WithWatcherCoordinator(hpprof.NewCoordinator(0, 0)),
+	)
+	assert.Nil(t, err)
+	w.sample = func() map[Metric]float64 {
+		return map[Metric]float64{Goroutines: 10, CPUPercent: 100}
+	}
+	w.Start()
+	time.Sleep(300 * time.Millisecond)
+	w.Stop()
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 2, len(list))
+	assert.DeepEqual(t, CPU, list[0].Type)
+	assert.DeepEqual(t, map[string]string{"metric": "cpu_percent", "value": "100", "trigger": "threshold"}, list[0].Labels)
+	assert.DeepEqual(t, Goroutine, list[1].Type)
+	assert.DeepEqual(t, WatcherSource, list[1].Source)
+}
+
+func TestWatcherCaptureFailed(t *testing.T) {
+	co := hpprof.NewCoordinator(0, 0)
+	s := store.NewMemoryStore(10)
+	w, err := NewWatcher(s,
+		WithRule(Rule{Metric: CPUPercent, Threshold: 1, Duration: 10 * time.Millisecond}),
+		WithCooldown(time.Hour),
+		WithMaxCapturesPerHour(1),
+		WithWatcherCoordinator(co),
+	)
+	assert.Nil(t, err)
+	w.sample = func() map[Metric]float64 {
+		return map[Metric]float64{CPUPercent: 100}
+	}
+
+	// a capture failing while another one runs neither cools down the rule nor counts
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+	now := time.Now()
+	w.check(context.Background(), now)
+	release()
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 0, len(list))
+
+	w.check(context.Background(), now.Add(time.Second))
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 1, len(list))
+
+	w.check(context.Background(), now.Add(2*time.Second))
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 1, len(list))
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var defaultCoordinator = NewCoordinator(0, 0)
+
+// DefaultCoordinator returns the Coordinator shared by every pprof and fgprof route
+// group registered without WithCoordinator.
+func DefaultCoordinator() *Coordinator {
+	return defaultCoordinator
+}
+
+// Coordinator makes sure only one capture among CPU profile, execution trace and
+// fgprof runs at a time. Later captures either wait for the running one to finish
+// or are rejected with the current holder and its remaining time.
+type Coordinator struct {
+	slot        chan struct{}
+	waitTimeout time.Duration
+	maxWaiters  int
+
+	mu      sync.Mutex
+	holder  *Holder
+	waiters int
+}
+
+// Holder describes the capture currently running.
+type Holder struct {
+	Name      string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Remaining returns how long the capture is expected to run for.
+func (h *Holder) Remaining() time.Duration {
+	if remaining := time.Until(h.StartedAt.Add(h.Duration)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// BusyError is returned by Coordinator.Acquire when the capture could not start.
+type BusyError struct {
+	// Holder is the running capture, nil if it finished in the meantime.
+	Holder *Holder
+	// QueueFull is set if the capture was rejected without waiting as too many are waiting already.
+	QueueFull bool
+}
+
+func (e *BusyError) Error() string {
+	if e.Holder == nil {
+		return "pprof: capture in progress"
+	}
+	return fmt.Sprintf("pprof: %s capture in progress, %s remaining", e.Holder.Name, e.Holder.Remaining().Round(time.Second))
+}
+
+// NewCoordinator returns a Coordinator where captures wait up to waitTimeout for the
+// running one to finish, 0 means to fail immediately. At most maxWaiters captures may
+// wait at the same time, 0 means no limit.
+func NewCoordinator(waitTimeout time.Duration, maxWaiters int) *Coordinator {
+	return &Coordinator{
+		slot:        make(chan struct{}, 1),
+		waitTimeout: waitTimeout,
+		maxWaiters:  maxWaiters,
+	}
+}
+
+// Acquire blocks until the capture called name, expected to run for d, may start.
+// The returned release func must be called once the capture is done. The error is
+// a *BusyError if the capture could not start in time, or ctx.Err().
+func (co *Coordinator) Acquire(ctx context.Context, name string, d time.Duration) (release func(), err error) {
+	select {
+	case co.slot <- struct{}{}:
+		return co.hold(name, d), nil
+	default:
+	}
+	if co.waitTimeout <= 0 {
+		return nil, co.busy(false)
+	}
+
+	co.mu.Lock()
+	if co.maxWaiters > 0 && co.waiters >= co.maxWaiters {
+		co.mu.Unlock()
+		return nil, co.busy(true)
+	}
+	co.waiters++
+	co.mu.Unlock()
+	defer func() {
+		co.mu.Lock()
+		co.waiters--
+		co.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(co.waitTimeout)
+	defer timer.Stop()
+	select {
+	case co.slot <- struct{}{}:
+		return co.hold(name, d), nil
+	case <-timer.C:
+		return nil, co.busy(false)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tryAcquire is Acquire without waiting for the running capture to finish.
+func (co *Coordinator) tryAcquire(name string, d time.Duration) (release func(), err error) {
+	select {
+	case co.slot <- struct{}{}:
+		return co.hold(name, d), nil
+	default:
+		return nil, co.busy(false)
+	}
+}
+
+// Holder returns the running capture, nil if there is none.
+func (co *Coordinator) Holder() *Holder {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if co.holder == nil {
+		return nil
+	}
+	h := *co.holder
+	return &h
+}
+
+func (co *Coordinator) hold(name string, d time.Duration) func() {
+	co.mu.Lock()
+	co.holder = &Holder{Name: name, StartedAt: time.Now(), Duration: d}
+	co.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			co.mu.Lock()
+			co.holder = nil
+			co.mu.Unlock()
+			<-co.slot
+		})
+	}
+}
+
+func (co *Coordinator) busy(queueFull bool) *BusyError {
+	return &BusyError{Holder: co.Holder(), QueueFull: queueFull}
+}
+
+// middleware holds the coordinator for the lifetime of the request of the capture called
+// name, which lasts for the seconds query parameter, defaultSeconds if absent or invalid.
+func (co *Coordinator) middleware(name string, defaultSeconds int) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		d, err := captureDuration(c, name, defaultSeconds)
+		if err != nil {
+			d = time.Duration(defaultSeconds) * time.Second
+		}
+		release, err := co.Acquire(ctx, name, d)
+		if err != nil {
+			abortBusy(c, err)
+			return
+		}
+		defer release()
+		c.Next(ctx)
+	}
+}
+
+// maxCaptureSeconds is the longest capture asked in seconds which fits in a time.Duration.
+const maxCaptureSeconds = math.MaxInt64 / int64(time.Second)
+
+// captureDuration returns the duration asked by the seconds query parameter of the capture
+// called name, parsed like its handler does: as a float for trace, as an integer for the
+// other ones. It is defaultSeconds if the parameter is absent or not positive, and fails if
+// the parameter cannot be parsed or overflows a time.Duration.
+func captureDuration(c *app.RequestContext, name string, defaultSeconds int) (time.Duration, error) {
+	s := string(c.FormValue("seconds"))
+	if s == "" {
+		return time.Duration(defaultSeconds) * time.Second, nil
+	}
+
+	var sec float64
+	if name == "trace" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, fmt.Errorf("invalid seconds %q", s)
+		}
+		sec = f
+	} else {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid seconds %q, want an integer", s)
+		}
+		sec = float64(n)
+	}
+	if sec <= 0 {
+		return time.Duration(defaultSeconds) * time.Second, nil
+	}
+	if sec > float64(maxCaptureSeconds) {
+		return 0, fmt.Errorf("seconds %s exceeds the maximum of %d", s, maxCaptureSeconds)
+	}
+	return time.Duration(sec * float64(time.Second)), nil
+}
+
+// abortBusy responds 409 if the capture waited in vain, 429 if it was not even allowed
+// to wait, along with the running capture if any.
+func abortBusy(c *app.RequestContext, err error) {
+	busy, ok := err.(*BusyError)
+	if !ok {
+		c.AbortWithMsg(err.Error(), consts.StatusServiceUnavailable)
+		return
+	}
+
+	status := consts.StatusConflict
+	if busy.QueueFull {
+		status = consts.StatusTooManyRequests
+	}
+	body := utils.H{"error": busy.Error()}
+	if busy.Holder != nil {
+		remaining := busy.Holder.Remaining()
+		body["holder"] = busy.Holder.Name
+		body["started_at"] = busy.Holder.StartedAt.Format(time.RFC3339)
+		body["remaining_seconds"] = remaining.Seconds()
+		c.Response.Header.Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+	}
+	c.AbortWithStatusJSON(status, body)
+}
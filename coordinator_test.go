@@ -0,0 +1,127 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func Test_Coordinator_Acquire(t *testing.T) {
+	co := NewCoordinator(0, 0)
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "profile", co.Holder().Name)
+
+	_, err = co.Acquire(context.Background(), "trace", time.Second)
+	busy, ok := err.(*BusyError)
+	assert.DeepEqual(t, true, ok)
+	assert.DeepEqual(t, false, busy.QueueFull)
+	assert.DeepEqual(t, "profile", busy.Holder.Name)
+	assert.DeepEqual(t, true, busy.Holder.Remaining() > 59*time.Second)
+
+	release()
+	release()
+	assert.Nil(t, co.Holder())
+	release, err = co.Acquire(context.Background(), "trace", time.Second)
+	assert.Nil(t, err)
+	release()
+}
+
+func Test_Coordinator_Wait(t *testing.T) {
+	co := NewCoordinator(time.Second, 1)
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+
+	acquired := make(chan error)
+	go func() {
+		release, err := co.Acquire(context.Background(), "fgprof", time.Second)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	for {
+		co.mu.Lock()
+		waiters := co.waiters
+		co.mu.Unlock()
+		if waiters == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = co.Acquire(context.Background(), "trace", time.Second)
+	busy, ok := err.(*BusyError)
+	assert.DeepEqual(t, true, ok)
+	assert.DeepEqual(t, true, busy.QueueFull)
+
+	release()
+	assert.Nil(t, <-acquired)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release, err = co.Acquire(ctx, "profile", time.Minute)
+	assert.Nil(t, err)
+	cancel()
+	_, err = co.Acquire(ctx, "trace", time.Second)
+	assert.DeepEqual(t, context.Canceled, err)
+	release()
+
+	co = NewCoordinator(10*time.Millisecond, 0)
+	release, err = co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+	_, err = co.Acquire(context.Background(), "trace", time.Second)
+	busy, ok = err.(*BusyError)
+	assert.DeepEqual(t, true, ok)
+	assert.DeepEqual(t, false, busy.QueueFull)
+	release()
+}
+
+func Test_Coordinator_Routes(t *testing.T) {
+	co := NewCoordinator(0, 0)
+	h := server.New()
+	RegisterWithOptions(h, WithCoordinator(co))
+	FgprofRegisterWithOptions(h, WithCoordinator(co))
+
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+
+	for _, target := range []string{"/debug/pprof/profile?seconds=1", "/debug/pprof/trace", "/debug/fgprof/?seconds=1"} {
+		resp := ut.PerformRequest(h.Engine, http.MethodGet, target, nil)
+		assert.DeepEqual(t, http.StatusConflict, resp.Code)
+		assert.DeepEqual(t, "60", resp.Header().Get("Retry-After"))
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.DeepEqual(t, true, bytes.Contains(b, []byte(`"holder":"profile"`)))
+	}
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	release()
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/trace", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	assert.Nil(t, co.Holder())
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/internal/strutil"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// ProfileBaselineHeader is the response header carrying the ID of the stored profile
+// a delta profile is computed from.
+const ProfileBaselineHeader = "X-Hertz-Profile-Baseline"
+
+// deltaBaselines are the types of the stored profiles a delta profile of the cumulative
+// profiles may be computed from, the heap and allocs profiles holding the same samples.
+var deltaBaselines = map[string][]string{
+	"allocs": {"allocs", "heap"},
+	"heap":   {"heap", "allocs"},
+	"block":  {"block"},
+	"mutex":  {"mutex"},
+}
+
+// deltaHandler serves the delta of the cumulative profile name between a profile of s
+// and now, with the delta=since:<id> or window=<duration> query parameters, without
+// blocking like the seconds one. The requests without them are served by h.
+func deltaHandler(s store.Store, name string, h app.HandlerFunc) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		delta, window := c.Query("delta"), c.Query("window")
+		if delta == "" && window == "" {
+			h(ctx, c)
+			return
+		}
+		if delta != "" && window != "" {
+			abortDelta(c, consts.StatusBadRequest, errors.New("delta and window params are incompatible"))
+			return
+		}
+		if c.Query("seconds") != "" || (c.Query("debug") != "" && c.Query("debug") != "0") {
+			abortDelta(c, consts.StatusBadRequest, errors.New("seconds and debug params are incompatible with delta profiles"))
+			return
+		}
+
+		var baseline store.Profile
+		var data []byte
+		var err error
+		if delta != "" {
+			id, ok := strutil.CutPrefix(delta, "since:")
+			if !ok {
+				abortDelta(c, consts.StatusBadRequest, fmt.Errorf("invalid delta %q, want since:<id>", delta))
+				return
+			}
+			baseline, data, err = s.Get(id)
+		} else {
+			var d time.Duration
+			if d, err = time.ParseDuration(window); err != nil || d <= 0 {
+				abortDelta(c, consts.StatusBadRequest, fmt.Errorf("invalid window %q", window))
+				return
+			}
+			baseline, data, err = findProfile(s, deltaBaselines[name], time.Now().Add(-d))
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			abortDelta(c, consts.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+			return
+		}
+		if !isDeltaBaseline(name, baseline.Type) {
+			abortDelta(c, consts.StatusBadRequest, fmt.Errorf("profile %s is a %s profile, not %s", baseline.ID, baseline.Type, name))
+			return
+		}
+
+		p, err := deltaProfile(name, baseline, data)
+		if err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+			return
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-delta"`, name))
+		c.Header(ProfileBaselineHeader, baseline.ID)
+		c.Response.Header.Set(consts.HeaderContentType, "application/octet-stream")
+		if err := p.Write(c.Response.BodyWriter()); err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+		}
+	}
+}
+
+// findProfile returns the newest stored profile of one of the given types which started
+// at or before the given time.
+func findProfile(s store.Store, types []string, before time.Time) (store.Profile, []byte, error) {
+	profiles, err := s.List()
+	if err != nil {
+		return store.Profile{}, nil, err
+	}
+	for _, p := range profiles {
+		for _, typ := range types {
+			if p.Type == typ && !p.StartedAt.After(before) {
+				return s.Get(p.ID)
+			}
+		}
+	}
+	return store.Profile{}, nil, fmt.Errorf("%w: no %s profile started before %s",
+		store.ErrNotFound, strings.Join(types, " or "), before.Format(time.RFC3339))
+}
+
+func isDeltaBaseline(name, typ string) bool {
+	for _, t := range deltaBaselines[name] {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// deltaProfile returns the difference between the profile name now and the baseline,
+// like net/http/pprof does with the seconds query parameter.
+func deltaProfile(name string, baseline store.Profile, data []byte) (*profile.Profile, error) {
+	p0, err := profile.ParseData(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+	p1, err := profile.ParseData(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	ts := p0.TimeNanos
+	if !baseline.StartedAt.IsZero() {
+		ts = baseline.StartedAt.UnixNano()
+	}
+	defaultSampleType := p1.DefaultSampleType
+	p0.Scale(-1)
+	p, err := profile.Merge([]*profile.Profile{p0, p1})
+	if err != nil {
+		return nil, err
+	}
+	p.TimeNanos = ts
+	p.DurationNanos = p1.TimeNanos - ts
+	p.DefaultSampleType = defaultSampleType
+	return p, nil
+}
+
+func abortDelta(c *app.RequestContext, status int, err error) {
+	c.AbortWithStatusJSON(status, utils.H{"error": err.Error()})
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+var deltaSink [][]byte
+
+func Test_Delta_Profile(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	snapshot := func(typ string, startedAt time.Time) store.Profile {
+		var buf bytes.Buffer
+		assert.Nil(t, pprof.Lookup(typ).WriteTo(&buf, 0))
+		p, err := s.Put(store.Profile{Type: typ, Source: "continuous", StartedAt: startedAt}, buf.Bytes())
+		assert.Nil(t, err)
+		return p
+	}
+	old := snapshot("heap", time.Now().Add(-10*time.Minute))
+	recent := snapshot("allocs", time.Now())
+	mutex := snapshot("mutex", time.Now().Add(-10*time.Minute))
+
+	for i := 0; i < 1000; i++ {
+		deltaSink = append(deltaSink, make([]byte, 1024))
+	}
+	deltaSink = nil
+	runtime.GC()
+
+	h := server.New()
+	RegisterWithOptions(h, WithStore(s))
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/allocs?window=5m", nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	assert.DeepEqual(t, old.ID, w.Header().Get(ProfileBaselineHeader))
+	assert.DeepEqual(t, `attachment; filename="allocs-delta"`, w.Header().Get("Content-Disposition"))
+	p, err := profile.ParseData(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.DeepEqual(t, old.StartedAt.UnixNano(), p.TimeNanos)
+	assert.True(t, p.DurationNanos >= int64(10*time.Minute))
+	assert.DeepEqual(t, "alloc_space", p.DefaultSampleType)
+
+	// the allocations since the baseline only
+	var allocated int64
+	for _, sample := range p.Sample {
+		allocated += sample.Value[1]
+	}
+	assert.True(t, allocated >= 1000*1024)
+	assert.True(t, allocated < 1000*1024*100)
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap?delta=since:"+recent.ID, nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	assert.DeepEqual(t, recent.ID, w.Header().Get(ProfileBaselineHeader))
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/mutex?window=1m", nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	assert.DeepEqual(t, mutex.ID, w.Header().Get(ProfileBaselineHeader))
+
+	for _, tt := range []struct {
+		url  string
+		code int
+	}{
+		{"/debug/pprof/block?window=1m", http.StatusNotFound},
+		{"/debug/pprof/heap?window=1h", http.StatusNotFound},
+		{"/debug/pprof/heap?delta=since:unknown", http.StatusNotFound},
+		{"/debug/pprof/block?delta=since:" + old.ID, http.StatusBadRequest},
+		{"/debug/pprof/heap?delta=" + old.ID, http.StatusBadRequest},
+		{"/debug/pprof/heap?window=forever", http.StatusBadRequest},
+		{"/debug/pprof/heap?window=5m&delta=since:" + old.ID, http.StatusBadRequest},
+		{"/debug/pprof/heap?window=5m&seconds=1", http.StatusBadRequest},
+		{"/debug/pprof/heap?window=5m&debug=1", http.StatusBadRequest},
+		{"/debug/pprof/heap?debug=1", http.StatusOK},
+	} {
+		w := ut.PerformRequest(h.Engine, http.MethodGet, tt.url, nil)
+		if w.Code != tt.code {
+			t.Errorf("%q. code = %v, want %v", tt.url, w.Code, tt.code)
+		}
+	}
+
+	// the delta params are discoverable
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/?format=json", nil)
+	assert.True(t, bytes.Contains(w.Body.Bytes(), []byte(`"path":"/debug/pprof/heap","kind":"cumulative","format":"pprof","params":["debug","gc","seconds","delta","window"]`)))
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// diffDefaultTop is how many regressions the diff summary lists unless asked otherwise.
+const diffDefaultTop = 10
+
+// diffSummary is the JSON summary of a diff, listing the functions whose flat value grew
+// the most from the base to the target profile.
+type diffSummary struct {
+	Base        string      `json:"base"`
+	Target      string      `json:"target"`
+	SampleType  string      `json:"sample_type"`
+	Unit        string      `json:"unit"`
+	BaseTotal   int64       `json:"base_total"`
+	TargetTotal int64       `json:"target_total"`
+	Regressions []diffEntry `json:"regressions"`
+}
+
+type diffEntry struct {
+	Function string `json:"function"`
+	Base     int64  `json:"base"`
+	Target   int64  `json:"target"`
+	Delta    int64  `json:"delta"`
+}
+
+// diffHandler serves the difference between two profiles of s, like go tool pprof -base:
+//   - base and target are the IDs of the profiles, or RFC 3339 timestamps standing for the
+//     newest profile of the given type started at or before them; without target, the
+//     profile is taken now.
+//   - format=json replies the top regressions instead of the pprof profile, top telling
+//     how many, of the sample_index sample type, the default one of the profile by default.
+func diffHandler(s store.Store) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		typ := c.Query("type")
+		base, baseData, status, err := resolveDiffProfile(s, "base", c.Query("base"), typ)
+		if err != nil {
+			abortDelta(c, status, err)
+			return
+		}
+		if typ == "" {
+			typ = base.Type
+		}
+
+		var target store.Profile
+		var targetData []byte
+		if c.Query("target") != "" {
+			target, targetData, status, err = resolveDiffProfile(s, "target", c.Query("target"), typ)
+		} else {
+			target, targetData, status, err = currentProfile(typ)
+		}
+		if err != nil {
+			abortDelta(c, status, err)
+			return
+		}
+
+		p0, err := profile.ParseData(baseData)
+		if err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+			return
+		}
+		p1, err := profile.ParseData(targetData)
+		if err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+			return
+		}
+
+		if c.Query("format") == "json" {
+			summary, err := summarizeDiff(p0, p1, c.Query("sample_index"), c.Query("top"))
+			if err != nil {
+				abortDelta(c, consts.StatusBadRequest, err)
+				return
+			}
+			summary.Base, summary.Target = base.ID, target.ID
+			c.JSON(consts.StatusOK, summary)
+			return
+		}
+
+		defaultSampleType := p1.DefaultSampleType
+		p0.Scale(-1)
+		p, err := profile.Merge([]*profile.Profile{p0, p1})
+		if err != nil {
+			abortDelta(c, consts.StatusBadRequest, fmt.Errorf("profiles %s and %s cannot be compared: %v", base.ID, target.ID, err))
+			return
+		}
+		p.DefaultSampleType = defaultSampleType
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-diff"`, typ))
+		c.Response.Header.Set(consts.HeaderContentType, "application/octet-stream")
+		if err := p.Write(c.Response.BodyWriter()); err != nil {
+			abortDelta(c, consts.StatusInternalServerError, err)
+		}
+	}
+}
+
+// resolveDiffProfile returns the profile of s the param query parameter stands for,
+// with the status to reply if it cannot.
+func resolveDiffProfile(s store.Store, param, value, typ string) (store.Profile, []byte, int, error) {
+	if value == "" {
+		return store.Profile{}, nil, consts.StatusBadRequest, fmt.Errorf("missing %s param", param)
+	}
+
+	var p store.Profile
+	var data []byte
+	var err error
+	if t, parseErr := time.Parse(time.RFC3339, value); parseErr == nil {
+		if typ == "" {
+			return store.Profile{}, nil, consts.StatusBadRequest, fmt.Errorf("type param required with the %s timestamp", param)
+		}
+		types, ok := deltaBaselines[typ]
+		if !ok {
+			types = []string{typ}
+		}
+		p, data, err = findProfile(s, types, t)
+	} else {
+		p, data, err = s.Get(value)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return store.Profile{}, nil, consts.StatusNotFound, err
+	}
+	if err != nil {
+		return store.Profile{}, nil, consts.StatusInternalServerError, err
+	}
+	return p, data, 0, nil
+}
+
+// currentProfile takes the profile typ now, which must be one of runtime/pprof.
+func currentProfile(typ string) (store.Profile, []byte, int, error) {
+	lookup := pprof.Lookup(typ)
+	if lookup == nil {
+		return store.Profile{}, nil, consts.StatusBadRequest, fmt.Errorf("target param required to compare %s profiles", typ)
+	}
+	var buf bytes.Buffer
+	p := store.Profile{ID: "now", Type: typ, StartedAt: time.Now()}
+	if err := lookup.WriteTo(&buf, 0); err != nil {
+		return store.Profile{}, nil, consts.StatusInternalServerError, err
+	}
+	return p, buf.Bytes(), 0, nil
+}
+
+// summarizeDiff returns the functions whose flat value of the sampleType sample type
+// grew the most from p0 to p1, top of them.
+func summarizeDiff(p0, p1 *profile.Profile, sampleType, top string) (*diffSummary, error) {
+	n := diffDefaultTop
+	if top != "" {
+		var err error
+		if n, err = strconv.Atoi(top); err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid top %q", top)
+		}
+	}
+	if sampleType == "" {
+		sampleType = p1.DefaultSampleType
+	}
+	if sampleType == "" && len(p1.SampleType) > 0 {
+		sampleType = p1.SampleType[len(p1.SampleType)-1].Type
+	}
+	i0, unit, ok := sampleIndex(p0, sampleType)
+	i1, _, ok1 := sampleIndex(p1, sampleType)
+	if !ok || !ok1 {
+		return nil, fmt.Errorf("sample type %q not in both profiles", sampleType)
+	}
+
+	base, baseTotal := flatByFunction(p0, i0)
+	target, targetTotal := flatByFunction(p1, i1)
+	regressions := []diffEntry{}
+	for name, v := range target {
+		if delta := v - base[name]; delta > 0 {
+			regressions = append(regressions, diffEntry{Function: name, Base: base[name], Target: v, Delta: delta})
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Delta != regressions[j].Delta {
+			return regressions[i].Delta > regressions[j].Delta
+		}
+		return regressions[i].Function < regressions[j].Function
+	})
+	if len(regressions) > n {
+		regressions = regressions[:n]
+	}
+
+	return &diffSummary{
+		SampleType:  sampleType,
+		Unit:        unit,
+		BaseTotal:   baseTotal,
+		TargetTotal: targetTotal,
+		Regressions: regressions,
+	}, nil
+}
+
+func sampleIndex(p *profile.Profile, sampleType string) (int, string, bool) {
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			return i, st.Unit, true
+		}
+	}
+	return 0, "", false
+}
+
+// flatByFunction returns the value i of the samples of p by leaf function, and their total.
+func flatByFunction(p *profile.Profile, i int) (map[string]int64, int64) {
+	flat := map[string]int64{}
+	var total int64
+	for _, s := range p.Sample {
+		total += s.Value[i]
+		name := "unknown"
+		if len(s.Location) > 0 && len(s.Location[0].Line) > 0 && s.Location[0].Line[0].Function != nil {
+			name = s.Location[0].Line[0].Function.Name
+		}
+		flat[name] += s.Value[i]
+	}
+	return flat, total
+}
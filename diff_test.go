@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// testHeapProfile returns a heap-like profile where each function allocated the given space.
+func testHeapProfile(t *testing.T, space map[string]int64) []byte {
+	p := &profile.Profile{
+		SampleType:        []*profile.ValueType{{Type: "alloc_objects", Unit: "count"}, {Type: "alloc_space", Unit: "bytes"}},
+		DefaultSampleType: "alloc_space",
+		PeriodType:        &profile.ValueType{Type: "space", Unit: "bytes"},
+	}
+	for _, name := range []string{"main.a", "main.b", "main.c"} {
+		id := uint64(len(p.Function) + 1)
+		f := &profile.Function{ID: id, Name: name}
+		l := &profile.Location{ID: id, Line: []profile.Line{{Function: f}}}
+		p.Function = append(p.Function, f)
+		p.Location = append(p.Location, l)
+		p.Sample = append(p.Sample, &profile.Sample{Location: []*profile.Location{l}, Value: []int64{1, space[name]}})
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func Test_Diff(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	now := time.Now().UTC().Truncate(time.Second)
+	base, err := s.Put(store.Profile{Type: "heap", StartedAt: now.Add(-time.Hour)},
+		testHeapProfile(t, map[string]int64{"main.a": 100, "main.b": 100, "main.c": 100}))
+	assert.Nil(t, err)
+	target, err := s.Put(store.Profile{Type: "heap", StartedAt: now},
+		testHeapProfile(t, map[string]int64{"main.a": 400, "main.b": 50, "main.c": 200}))
+	assert.Nil(t, err)
+	cpu, err := s.Put(store.Profile{Type: "cpu", StartedAt: now}, testHeapProfile(t, nil))
+	assert.Nil(t, err)
+
+	h := server.New()
+	RegisterWithOptions(h, WithStore(s))
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/diff?base="+base.ID+"&target="+target.ID, nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	assert.DeepEqual(t, `attachment; filename="heap-diff"`, w.Header().Get("Content-Disposition"))
+	p, err := profile.ParseData(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "alloc_space", p.DefaultSampleType)
+	diff := map[string]int64{}
+	for _, sample := range p.Sample {
+		diff[sample.Location[0].Line[0].Function.Name] += sample.Value[1]
+	}
+	assert.DeepEqual(t, map[string]int64{"main.a": 300, "main.b": -50, "main.c": 100}, diff)
+
+	// the same profiles by timestamp, as a summary
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/diff?type=heap&format=json&top=1&base="+
+		now.Add(-time.Minute).Format(time.RFC3339)+"&target="+now.Format(time.RFC3339), nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	var summary diffSummary
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.DeepEqual(t, diffSummary{
+		Base:        base.ID,
+		Target:      target.ID,
+		SampleType:  "alloc_space",
+		Unit:        "bytes",
+		BaseTotal:   300,
+		TargetTotal: 650,
+		Regressions: []diffEntry{{Function: "main.a", Base: 100, Target: 400, Delta: 300}},
+	}, summary)
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/diff?format=json&sample_index=alloc_objects&base="+base.ID+"&target="+target.ID, nil)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.DeepEqual(t, []diffEntry{}, summary.Regressions)
+
+	// without target, the heap profile is taken now
+	var buf bytes.Buffer
+	assert.Nil(t, pprof.Lookup("heap").WriteTo(&buf, 0))
+	current, err := s.Put(store.Profile{Type: "heap", StartedAt: now}, buf.Bytes())
+	assert.Nil(t, err)
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/diff?format=json&base="+current.ID, nil)
+	assert.DeepEqual(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.DeepEqual(t, "now", summary.Target)
+	assert.DeepEqual(t, "inuse_space", summary.SampleType)
+
+	for _, tt := range []struct {
+		url  string
+		code int
+	}{
+		{"/debug/pprof/diff", http.StatusBadRequest},
+		{"/debug/pprof/diff?base=unknown", http.StatusNotFound},
+		{"/debug/pprof/diff?base=" + now.Format(time.RFC3339), http.StatusBadRequest},
+		{"/debug/pprof/diff?type=heap&base=" + now.Add(-2*time.Hour).Format(time.RFC3339), http.StatusNotFound},
+		{"/debug/pprof/diff?base=" + cpu.ID, http.StatusBadRequest},
+		{"/debug/pprof/diff?format=json&top=0&base=" + base.ID + "&target=" + target.ID, http.StatusBadRequest},
+		{"/debug/pprof/diff?format=json&sample_index=cpu&base=" + base.ID + "&target=" + target.ID, http.StatusBadRequest},
+	} {
+		w := ut.PerformRequest(h.Engine, http.MethodGet, tt.url, nil)
+		if w.Code != tt.code {
+			t.Errorf("%q. code = %v, want %v", tt.url, w.Code, tt.code)
+		}
+	}
+}
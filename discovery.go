@@ -0,0 +1,137 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// The kinds of the profiles listed by the discovery endpoint.
+const (
+	// KindDelta profiles sample for a while, e.g. the CPU profile.
+	KindDelta = "delta"
+	// KindCumulative profiles accumulate since the process started, e.g. the allocations,
+	// a delta over a while is returned with the seconds query parameter.
+	KindCumulative = "cumulative"
+	// KindSnapshot profiles describe the process at the time of the request, e.g. the goroutines.
+	KindSnapshot = "snapshot"
+)
+
+var profileKinds = map[string]string{
+	"allocs":       KindCumulative,
+	"block":        KindCumulative,
+	"goroutine":    KindSnapshot,
+	"heap":         KindCumulative,
+	"mutex":        KindCumulative,
+	"profile":      KindDelta,
+	"threadcreate": KindCumulative,
+	"trace":        KindDelta,
+	"fgprof":       KindDelta,
+}
+
+var profileFormats = map[string]string{
+	"cmdline":  "text",
+	"profiles": "json",
+	"symbol":   "text",
+	"trace":    "trace",
+}
+
+var profileParams = map[string][]string{
+	"allocs":       {"debug", "seconds"},
+	"block":        {"debug", "seconds"},
+	"diff":         {"base", "target", "type", "format", "sample_index", "top"},
+	"goroutine":    {"debug", "seconds"},
+	"heap":         {"debug", "gc", "seconds"},
+	"mutex":        {"debug", "seconds"},
+	"profile":      {"seconds"},
+	"profiles":     {"type", "source"},
+	"threadcreate": {"debug", "seconds"},
+	"trace":        {"seconds"},
+	"fgprof":       {"seconds", "format"},
+}
+
+// discovery is the machine-readable index, e.g. to generate the scrape configuration of
+// a profiling agent.
+type discovery struct {
+	// Fgprof tells whether fgprof is mounted, its paths are listed with the profiles.
+	Fgprof   bool                `json:"fgprof"`
+	Profiles []discoveredProfile `json:"profiles"`
+}
+
+type discoveredProfile struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Kind        string   `json:"kind,omitempty"`
+	Format      string   `json:"format"`
+	Params      []string `json:"params"`
+	Description string   `json:"description,omitempty"`
+}
+
+// serveDiscovery replies the discovery document of the endpoints mounted under base and
+// of fgprof mounted on fgprofPaths, delta telling whether the cumulative profiles are
+// served as delta profiles too.
+func serveDiscovery(c *app.RequestContext, base string, mounted map[string]bool, delta bool, fgprofPaths []string) {
+	d := discovery{Profiles: []discoveredProfile{}}
+	for name := range mounted {
+		p := newDiscoveredProfile(name, path.Join(base, name))
+		if _, ok := deltaBaselines[name]; ok && delta {
+			p.Params = append(p.Params[:len(p.Params):len(p.Params)], "delta", "window")
+		}
+		d.Profiles = append(d.Profiles, p)
+	}
+	sort.Slice(d.Profiles, func(i, j int) bool {
+		return d.Profiles[i].Name < d.Profiles[j].Name
+	})
+
+	for _, p := range fgprofPaths {
+		d.Profiles = append(d.Profiles, newDiscoveredProfile("fgprof", p))
+	}
+	d.Fgprof = len(fgprofPaths) > 0
+
+	c.Response.Header.Set("X-Content-Type-Options", "nosniff")
+	c.JSON(consts.StatusOK, d)
+}
+
+func newDiscoveredProfile(name, p string) discoveredProfile {
+	format := profileFormats[name]
+	if format == "" {
+		format = "pprof"
+	}
+	params := profileParams[name]
+	if params == nil {
+		params = []string{}
+	}
+	desc := profileDescriptions[name]
+	if name == "fgprof" {
+		desc = "Wall-clock profile of all goroutines, on and off CPU. You can specify the duration in the seconds GET parameter, and the format among pprof and folded."
+		// fgprof is mounted on the root of its group
+		p = strings.TrimSuffix(p, "/") + "/"
+	}
+	return discoveredProfile{
+		Name:        name,
+		Path:        p,
+		Kind:        profileKinds[name],
+		Format:      format,
+		Params:      params,
+		Description: desc,
+	}
+}
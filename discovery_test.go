@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func Test_Discovery(t *testing.T) {
+	h := server.New()
+	RegisterWithOptions(h, WithPrefix("/admin/pprof"), WithEndpoints("heap", "goroutine", "profile", "cmdline"),
+		WithFgprof("/admin/fgprof"))
+	FgprofRouteRegister(h.Group("/admin"), "fgprof")
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/admin/pprof/?format=json", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	assert.DeepEqual(t, "application/json; charset=utf-8", string(resp.Header().ContentType()))
+
+	var d discovery
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &d))
+	assert.True(t, d.Fgprof)
+
+	profiles := map[string]discoveredProfile{}
+	for _, p := range d.Profiles {
+		profiles[p.Name] = p
+	}
+	assert.DeepEqual(t, 5, len(profiles))
+	assert.DeepEqual(t, discoveredProfile{
+		Name:        "heap",
+		Path:        "/admin/pprof/heap",
+		Kind:        KindCumulative,
+		Format:      "pprof",
+		Params:      []string{"debug", "gc", "seconds"},
+		Description: profileDescriptions["heap"],
+	}, profiles["heap"])
+	assert.DeepEqual(t, KindSnapshot, profiles["goroutine"].Kind)
+	assert.DeepEqual(t, KindDelta, profiles["profile"].Kind)
+	assert.DeepEqual(t, "", profiles["cmdline"].Kind)
+	assert.DeepEqual(t, "text", profiles["cmdline"].Format)
+	assert.DeepEqual(t, []string{}, profiles["cmdline"].Params)
+	assert.DeepEqual(t, KindDelta, profiles["fgprof"].Kind)
+	assert.DeepEqual(t, "/admin/fgprof/", profiles["fgprof"].Path)
+	assert.DeepEqual(t, []string{"seconds", "format"}, profiles["fgprof"].Params)
+
+	// the html index is unchanged
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/admin/pprof/", nil)
+	assert.DeepEqual(t, "text/html; charset=utf-8", string(resp.Header().ContentType()))
+}
+
+func Test_Discovery_Without_Fgprof(t *testing.T) {
+	// fgprof mounted on another server is not listed
+	admin := NewAdminServer("127.0.0.1:0")
+	resp := ut.PerformRequest(admin.Engine, http.MethodGet, "/debug/pprof/?format=json", nil)
+	var d discovery
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &d))
+	assert.True(t, d.Fgprof)
+
+	h := server.New()
+	Register(h)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/?format=json", nil)
+	d = discovery{}
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &d))
+	assert.False(t, d.Fgprof)
+	for _, p := range d.Profiles {
+		assert.True(t, p.Name != "fgprof")
+	}
+}
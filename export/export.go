@@ -0,0 +1,282 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package export sends the profiles captured by this module to profiling backends.
+//
+// A Queue wraps the store.Store the profiles are put in, e.g. by the continuous package,
+// and hands them to an Exporter in the background, e.g. Pyroscope or OTLP:
+//
+//	q := export.NewQueue(profiles, export.NewPyroscope("http://pyroscope:4040", "app"))
+//	_, err := continuous.Attach(h, q)
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// Exporter sends profiles to a profiling backend.
+type Exporter interface {
+	// Export sends the profile p made of data. The types of profiles the backend does not
+	// support are skipped without error.
+	Export(ctx context.Context, p store.Profile, data []byte) error
+}
+
+// ExporterFunc is an adapter to allow the use of ordinary functions as Exporter.
+type ExporterFunc func(ctx context.Context, p store.Profile, data []byte) error
+
+// Export calls f(ctx, p, data).
+func (f ExporterFunc) Export(ctx context.Context, p store.Profile, data []byte) error {
+	return f(ctx, p, data)
+}
+
+// HTTPError is returned by the exporters when the backend replies with an unexpected status.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("export: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// permanentError is an error retrying does not help with, e.g. an invalid profile.
+type permanentError struct {
+	err error
+}
+
+func permanent(err error) error {
+	return &permanentError{err}
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether an export failing with err may succeed later, that is unless
+// the profile is invalid or the backend rejected it.
+func retryable(err error) bool {
+	var permanentErr *permanentError
+	if errors.As(err, &permanentErr) {
+		return false
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return true
+	}
+	return httpErr.StatusCode >= 500 || httpErr.StatusCode == 408 || httpErr.StatusCode == 429
+}
+
+// ErrClosed is returned by Queue.Shutdown once the Queue is shut down.
+var ErrClosed = errors.New("export: queue closed")
+
+// QueueOption configures a Queue.
+type QueueOption func(o *queueOptions)
+
+type queueOptions struct {
+	size    int
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+// WithQueueSize sets how many profiles may wait to be exported, 64 by default.
+// The profiles put while the queue is full are not exported.
+func WithQueueSize(n int) QueueOption {
+	return func(o *queueOptions) {
+		o.size = n
+	}
+}
+
+// WithRetries sets how many times a failed export is retried, 3 by default.
+func WithRetries(n int) QueueOption {
+	return func(o *queueOptions) {
+		o.retries = n
+	}
+}
+
+// WithBackoff sets the wait before the first retry, doubled for each following one,
+// 1 second by default.
+func WithBackoff(d time.Duration) QueueOption {
+	return func(o *queueOptions) {
+		o.backoff = d
+	}
+}
+
+// WithExportTimeout bounds each export attempt, 30 seconds by default.
+func WithExportTimeout(d time.Duration) QueueOption {
+	return func(o *queueOptions) {
+		o.timeout = d
+	}
+}
+
+type queued struct {
+	profile store.Profile
+	data    []byte
+}
+
+// Queue is a store.Store exporting the profiles put in it in the background, in the order
+// they are put. Failed exports are retried, and the profiles put while too many are waiting
+// are dropped.
+type Queue struct {
+	store    store.Store
+	exporter Exporter
+	o        *queueOptions
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan queued
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewQueue returns a Queue keeping the profiles in s, nil to only export them, and exporting
+// them with e until shut down.
+func NewQueue(s store.Store, e Exporter, opts ...QueueOption) *Queue {
+	o := &queueOptions{
+		size:    64,
+		retries: 3,
+		backoff: time.Second,
+		timeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	q := &Queue{
+		store:    s,
+		exporter: e,
+		o:        o,
+		queue:    make(chan queued, o.size),
+		done:     make(chan struct{}),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	go q.run()
+	return q
+}
+
+// Put implements store.Store, queueing the profile for export once it is stored.
+func (q *Queue) Put(p store.Profile, data []byte) (store.Profile, error) {
+	if q.store != nil {
+		var err error
+		if p, err = q.store.Put(p, data); err != nil {
+			return p, err
+		}
+	} else {
+		if p.ID == "" {
+			p.ID = store.NewID()
+		}
+		p.Size = int64(len(data))
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return p, nil
+	}
+	select {
+	case q.queue <- queued{p, data}:
+	default:
+		hlog.SystemLogger().Warnf("export queue full, %s profile id=%s dropped", p.Type, p.ID)
+	}
+	return p, nil
+}
+
+// Get implements store.Store.
+func (q *Queue) Get(id string) (store.Profile, []byte, error) {
+	if q.store == nil {
+		return store.Profile{}, nil, store.ErrNotFound
+	}
+	return q.store.Get(id)
+}
+
+// List implements store.Store.
+func (q *Queue) List() ([]store.Profile, error) {
+	if q.store == nil {
+		return nil, nil
+	}
+	return q.store.List()
+}
+
+// Shutdown stops queueing the profiles and waits for the queued ones to be exported,
+// or for ctx to be done, giving up on the remaining ones.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	q.closed = true
+	close(q.queue)
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	defer q.cancel()
+	for item := range q.queue {
+		if q.ctx.Err() != nil {
+			continue
+		}
+		if err := q.export(item); err != nil {
+			hlog.SystemLogger().Errorf("export %s profile id=%s error: %v", item.profile.Type, item.profile.ID, err)
+		}
+	}
+}
+
+// export exports item, retrying with backoff.
+func (q *Queue) export(item queued) error {
+	backoff := q.o.backoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(q.ctx, q.o.timeout)
+		err := q.exporter.Export(ctx, item.profile, item.data)
+		cancel()
+		if err == nil || attempt >= q.o.retries || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-q.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
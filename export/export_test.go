@@ -0,0 +1,147 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// testProfile returns a profile of the given sample types made of the samples built by
+// samples on the locations of main.handler, at main.go:12, and main.serve, at main.go:25.
+func testProfile(sampleTypes []*profile.ValueType, samples func(handler, serve *profile.Location) []*profile.Sample) *profile.Profile {
+	m := &profile.Mapping{ID: 1, Start: 0x1000, Limit: 0x2000, File: "/bin/app"}
+	handler := &profile.Function{ID: 1, Name: "main.handler", SystemName: "main.handler", Filename: "main.go", StartLine: 10}
+	serve := &profile.Function{ID: 2, Name: "main.serve", SystemName: "main.serve", Filename: "main.go", StartLine: 20}
+	l1 := &profile.Location{ID: 1, Mapping: m, Address: 0x1010, Line: []profile.Line{{Function: handler, Line: 12}}}
+	l2 := &profile.Location{ID: 2, Mapping: m, Address: 0x1020, Line: []profile.Line{{Function: serve, Line: 25}}}
+	return &profile.Profile{
+		SampleType: sampleTypes,
+		Sample:     samples(l1, l2),
+		Mapping:    []*profile.Mapping{m},
+		Location:   []*profile.Location{l1, l2},
+		Function:   []*profile.Function{handler, serve},
+	}
+}
+
+// encodeProfile returns p in the pprof format.
+func encodeProfile(t *testing.T, p *profile.Profile) []byte {
+	var buf bytes.Buffer
+	assert.Nil(t, p.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestQueue(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	release := make(chan struct{})
+	e := ExporterFunc(func(ctx context.Context, p store.Profile, data []byte) error {
+		if p.Type == "block" {
+			<-release
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[p.Type]++
+		switch p.Type {
+		case "cpu":
+			if attempts[p.Type] < 3 {
+				return &HTTPError{StatusCode: 503}
+			}
+		case "heap":
+			return &HTTPError{StatusCode: 400}
+		case "goroutine":
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	s := store.NewMemoryStore(10)
+	q := NewQueue(s, e, WithQueueSize(1), WithRetries(2), WithBackoff(time.Millisecond))
+
+	// the queue is stuck on the first profile, the third one does not fit
+	for _, typ := range []string{"block", "cpu", "mutex"} {
+		_, err := q.Put(store.Profile{Type: typ}, []byte(typ))
+		assert.Nil(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	for _, typ := range []string{"heap", "goroutine"} {
+		_, err := q.Put(store.Profile{Type: typ}, []byte(typ))
+		assert.Nil(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, q.Shutdown(context.Background()))
+	assert.DeepEqual(t, ErrClosed, q.Shutdown(context.Background()))
+
+	// retried unless rejected
+	assert.DeepEqual(t, map[string]int{"cpu": 3, "heap": 1, "goroutine": 3}, attempts)
+
+	// every profile is stored
+	list, err := q.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 5, len(list))
+	_, data, err := q.Get(list[0].ID)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "goroutine", string(data))
+
+	// profiles put once shut down are stored only
+	_, err = q.Put(store.Profile{Type: "cpu"}, nil)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 3, attempts["cpu"])
+}
+
+func TestQueueWithoutStore(t *testing.T) {
+	exported := make(chan store.Profile, 1)
+	q := NewQueue(nil, ExporterFunc(func(ctx context.Context, p store.Profile, data []byte) error {
+		exported <- p
+		return nil
+	}))
+
+	p, err := q.Put(store.Profile{Type: "cpu"}, []byte("cpu"))
+	assert.Nil(t, err)
+	assert.True(t, p.ID != "")
+	assert.DeepEqual(t, int64(3), p.Size)
+	assert.DeepEqual(t, p, <-exported)
+
+	_, _, err = q.Get(p.ID)
+	assert.DeepEqual(t, store.ErrNotFound, err)
+	list, err := q.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 0, len(list))
+
+	// the export in progress is canceled once ctx is done
+	q = NewQueue(nil, ExporterFunc(func(ctx context.Context, p store.Profile, data []byte) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	_, err = q.Put(store.Profile{Type: "cpu"}, nil)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.DeepEqual(t, context.DeadlineExceeded, q.Shutdown(ctx))
+}
@@ -0,0 +1,512 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+const (
+	// otlpProfilesPath is the path of the profiles signal of OTLP/HTTP, still in development.
+	otlpProfilesPath = "/v1development/profiles"
+	otlpScopeName    = "github.com/hertz-contrib/pprof"
+)
+
+// OTLPOption configures an OTLP exporter.
+type OTLPOption func(o *OTLP)
+
+// WithOTLPResourceAttributes adds attributes to the resource of the profiles, on top of
+// service.name and the ones describing the process.
+func WithOTLPResourceAttributes(attributes map[string]string) OTLPOption {
+	return func(o *OTLP) {
+		for k, v := range attributes {
+			o.resource[k] = v
+		}
+	}
+}
+
+// WithOTLPTraceLabels sets the pprof labels carrying the trace and span IDs of a sample,
+// in hex, "trace_id" and "span_id" by default.
+func WithOTLPTraceLabels(traceID, spanID string) OTLPOption {
+	return func(o *OTLP) {
+		o.traceLabel, o.spanLabel = traceID, spanID
+	}
+}
+
+// WithOTLPHeader sets a header of every request, e.g. "Authorization" for an
+// authenticated collector.
+func WithOTLPHeader(key, value string) OTLPOption {
+	return func(o *OTLP) {
+		o.header.Set(key, value)
+	}
+}
+
+// WithOTLPClient sets the client sending the profiles, http.DefaultClient by default.
+func WithOTLPClient(c *http.Client) OTLPOption {
+	return func(o *OTLP) {
+		o.client = c
+	}
+}
+
+// OTLP is an Exporter converting the pprof profiles to the OpenTelemetry profiles signal,
+// sent to a collector over OTLP/HTTP in the JSON encoding. The profiles signal is still in
+// development, the v1development version of its data model is the one sent.
+//
+// Each sample type of a pprof profile becomes a profile of the signal, the pprof labels
+// become attributes of the samples, except the ones carrying the trace and span IDs which
+// link the samples to their span, see WithOTLPTraceLabels.
+type OTLP struct {
+	url        string
+	resource   map[string]string
+	traceLabel string
+	spanLabel  string
+	header     http.Header
+	client     *http.Client
+}
+
+// NewOTLP returns an OTLP exporter sending the profiles of the service serviceName to the
+// collector at endpoint, e.g. "http://otel-collector:4318".
+func NewOTLP(endpoint, serviceName string, opts ...OTLPOption) *OTLP {
+	o := &OTLP{
+		url: strings.TrimSuffix(endpoint, "/") + otlpProfilesPath,
+		resource: map[string]string{
+			"service.name":            serviceName,
+			"process.pid":             strconv.Itoa(os.Getpid()),
+			"process.runtime.name":    "go",
+			"process.runtime.version": runtime.Version(),
+			"telemetry.sdk.name":      "hertz",
+		},
+		traceLabel: "trace_id",
+		spanLabel:  "span_id",
+		header:     http.Header{},
+		client:     http.DefaultClient,
+	}
+	if host, err := os.Hostname(); err == nil {
+		o.resource["host.name"] = host
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Export implements Exporter.
+func (o *OTLP) Export(ctx context.Context, p store.Profile, data []byte) error {
+	prof, err := profile.ParseData(data)
+	if err != nil {
+		return permanent(err)
+	}
+	body, err := json.Marshal(o.convert(p, prof))
+	if err != nil {
+		return permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range o.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	io.Copy(io.Discard, resp.Body) // nolint:errcheck
+	return nil
+}
+
+// convert converts the pprof profile prof, stored as p, to an export request.
+func (o *OTLP) convert(p store.Profile, prof *profile.Profile) *otlpRequest {
+	d := newOTLPDictionary()
+
+	mappings := make(map[uint64]int32, len(prof.Mapping))
+	for _, m := range prof.Mapping {
+		mappings[m.ID] = int32(len(d.MappingTable))
+		d.MappingTable = append(d.MappingTable, otlpMapping{
+			MemoryStart:      strconv.FormatUint(m.Start, 10),
+			MemoryLimit:      strconv.FormatUint(m.Limit, 10),
+			FileOffset:       strconv.FormatUint(m.Offset, 10),
+			FilenameStrindex: d.str(m.File),
+		})
+	}
+	functions := make(map[uint64]int32, len(prof.Function))
+	for _, f := range prof.Function {
+		functions[f.ID] = int32(len(d.FunctionTable))
+		d.FunctionTable = append(d.FunctionTable, otlpFunction{
+			NameStrindex:       d.str(f.Name),
+			SystemNameStrindex: d.str(f.SystemName),
+			FilenameStrindex:   d.str(f.Filename),
+			StartLine:          strconv.FormatInt(f.StartLine, 10),
+		})
+	}
+	locations := make(map[uint64]int32, len(prof.Location))
+	for _, l := range prof.Location {
+		loc := otlpLocation{Address: strconv.FormatUint(l.Address, 10)}
+		if l.Mapping != nil {
+			loc.MappingIndex = mappings[l.Mapping.ID]
+		}
+		for _, line := range l.Line {
+			var function int32
+			if line.Function != nil {
+				function = functions[line.Function.ID]
+			}
+			loc.Lines = append(loc.Lines, otlpLine{FunctionIndex: function, Line: strconv.FormatInt(line.Line, 10)})
+		}
+		locations[l.ID] = int32(len(d.LocationTable))
+		d.LocationTable = append(d.LocationTable, loc)
+	}
+
+	// the samples are shared by the profiles of every sample type, but for their value.
+	samples := make([]otlpSample, len(prof.Sample))
+	for i, s := range prof.Sample {
+		indices := make([]int32, len(s.Location))
+		for j, l := range s.Location {
+			indices[j] = locations[l.ID]
+		}
+		samples[i].StackIndex = d.stack(indices)
+
+		var traceID, spanID string
+		for _, key := range sortedKeys(s.Label) {
+			for _, v := range s.Label[key] {
+				v := v
+				switch key {
+				case o.traceLabel:
+					traceID = v
+				case o.spanLabel:
+					spanID = v
+				default:
+					samples[i].AttributeIndices = append(samples[i].AttributeIndices, d.attribute(key, otlpAnyValue{StringValue: &v}, ""))
+				}
+			}
+		}
+		for _, key := range sortedKeys(s.NumLabel) {
+			for j, v := range s.NumLabel[key] {
+				unit := ""
+				if j < len(s.NumUnit[key]) {
+					unit = s.NumUnit[key][j]
+				}
+				samples[i].AttributeIndices = append(samples[i].AttributeIndices,
+					d.attribute(key, otlpAnyValue{IntValue: strconv.FormatInt(v, 10)}, unit))
+			}
+		}
+		samples[i].LinkIndex = d.link(traceID, spanID)
+	}
+
+	var attributes []int32
+	typ, source := p.Type, p.Source
+	attributes = append(attributes, d.attribute("profile.type", otlpAnyValue{StringValue: &typ}, ""))
+	if source != "" {
+		attributes = append(attributes, d.attribute("profile.source", otlpAnyValue{StringValue: &source}, ""))
+	}
+	for _, key := range sortedKeys(p.Labels) {
+		v := p.Labels[key]
+		attributes = append(attributes, d.attribute(key, otlpAnyValue{StringValue: &v}, ""))
+	}
+
+	timeNanos, durationNanos := prof.TimeNanos, prof.DurationNanos
+	if !p.StartedAt.IsZero() {
+		timeNanos = p.StartedAt.UnixNano()
+	}
+	if durationNanos == 0 {
+		durationNanos = p.Duration.Nanoseconds()
+	}
+	var periodType otlpValueType
+	if prof.PeriodType != nil {
+		periodType = otlpValueType{TypeStrindex: d.str(prof.PeriodType.Type), UnitStrindex: d.str(prof.PeriodType.Unit)}
+	}
+
+	profiles := make([]otlpProfile, 0, len(prof.SampleType))
+	for i, st := range prof.SampleType {
+		typed := make([]otlpSample, len(samples))
+		for j, s := range samples {
+			s.Values = []string{strconv.FormatInt(prof.Sample[j].Value[i], 10)}
+			typed[j] = s
+		}
+		profiles = append(profiles, otlpProfile{
+			SampleType:       otlpValueType{TypeStrindex: d.str(st.Type), UnitStrindex: d.str(st.Unit)},
+			Samples:          typed,
+			TimeUnixNano:     strconv.FormatInt(timeNanos, 10),
+			DurationNano:     strconv.FormatInt(durationNanos, 10),
+			PeriodType:       periodType,
+			Period:           strconv.FormatInt(prof.Period, 10),
+			ProfileID:        newProfileID(),
+			AttributeIndices: attributes,
+		})
+	}
+
+	resource := make([]otlpKeyValue, 0, len(o.resource))
+	for _, key := range sortedKeys(o.resource) {
+		v := o.resource[key]
+		resource = append(resource, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &v}})
+	}
+	return &otlpRequest{
+		ResourceProfiles: []otlpResourceProfiles{{
+			Resource: otlpResource{Attributes: resource},
+			ScopeProfiles: []otlpScopeProfiles{{
+				Scope:    otlpScope{Name: otlpScopeName},
+				Profiles: profiles,
+			}},
+		}},
+		Dictionary: d.otlpDictionary,
+	}
+}
+
+// newProfileID returns a random profile ID, hex encoded as the IDs of OTLP/JSON.
+func newProfileID() string {
+	var b [16]byte
+	rand.Read(b[:]) // nolint:errcheck
+	return hex.EncodeToString(b[:])
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The types below are the JSON encoding of the messages of the OTLP profiles signal,
+// 64-bit integers being encoded as strings.
+
+type otlpRequest struct {
+	ResourceProfiles []otlpResourceProfiles `json:"resourceProfiles"`
+	Dictionary       otlpDictionary         `json:"dictionary"`
+}
+
+type otlpResourceProfiles struct {
+	Resource      otlpResource        `json:"resource"`
+	ScopeProfiles []otlpScopeProfiles `json:"scopeProfiles"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+}
+
+type otlpScopeProfiles struct {
+	Scope    otlpScope     `json:"scope"`
+	Profiles []otlpProfile `json:"profiles"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpProfile struct {
+	SampleType       otlpValueType `json:"sampleType"`
+	Samples          []otlpSample  `json:"samples"`
+	TimeUnixNano     string        `json:"timeUnixNano"`
+	DurationNano     string        `json:"durationNano"`
+	PeriodType       otlpValueType `json:"periodType"`
+	Period           string        `json:"period"`
+	ProfileID        string        `json:"profileId"`
+	AttributeIndices []int32       `json:"attributeIndices,omitempty"`
+}
+
+type otlpValueType struct {
+	TypeStrindex int32 `json:"typeStrindex"`
+	UnitStrindex int32 `json:"unitStrindex"`
+}
+
+type otlpSample struct {
+	StackIndex       int32    `json:"stackIndex"`
+	Values           []string `json:"values"`
+	AttributeIndices []int32  `json:"attributeIndices,omitempty"`
+	LinkIndex        int32    `json:"linkIndex,omitempty"`
+}
+
+type otlpDictionary struct {
+	MappingTable   []otlpMapping         `json:"mappingTable"`
+	LocationTable  []otlpLocation        `json:"locationTable"`
+	FunctionTable  []otlpFunction        `json:"functionTable"`
+	LinkTable      []otlpLink            `json:"linkTable"`
+	StringTable    []string              `json:"stringTable"`
+	AttributeTable []otlpKeyValueAndUnit `json:"attributeTable"`
+	StackTable     []otlpStack           `json:"stackTable"`
+}
+
+type otlpMapping struct {
+	MemoryStart      string `json:"memoryStart"`
+	MemoryLimit      string `json:"memoryLimit"`
+	FileOffset       string `json:"fileOffset"`
+	FilenameStrindex int32  `json:"filenameStrindex"`
+}
+
+type otlpLocation struct {
+	MappingIndex int32      `json:"mappingIndex"`
+	Address      string     `json:"address"`
+	Lines        []otlpLine `json:"lines"`
+}
+
+type otlpLine struct {
+	FunctionIndex int32  `json:"functionIndex"`
+	Line          string `json:"line"`
+}
+
+type otlpFunction struct {
+	NameStrindex       int32  `json:"nameStrindex"`
+	SystemNameStrindex int32  `json:"systemNameStrindex"`
+	FilenameStrindex   int32  `json:"filenameStrindex"`
+	StartLine          string `json:"startLine"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpKeyValueAndUnit struct {
+	KeyStrindex  int32        `json:"keyStrindex"`
+	Value        otlpAnyValue `json:"value"`
+	UnitStrindex int32        `json:"unitStrindex"`
+}
+
+type otlpStack struct {
+	LocationIndices []int32 `json:"locationIndices"`
+}
+
+// dictionary builds the tables of an export request, deduplicating their entries.
+// The first entry of every table is the zero value, as required by OTLP.
+type dictionary struct {
+	otlpDictionary
+
+	strings    map[string]int32
+	stacks     map[string]int32
+	attributes map[string]int32
+	links      map[otlpLink]int32
+}
+
+func newOTLPDictionary() *dictionary {
+	return &dictionary{
+		otlpDictionary: otlpDictionary{
+			MappingTable:   []otlpMapping{{MemoryStart: "0", MemoryLimit: "0", FileOffset: "0"}},
+			LocationTable:  []otlpLocation{{Address: "0"}},
+			FunctionTable:  []otlpFunction{{StartLine: "0"}},
+			LinkTable:      []otlpLink{{}},
+			StringTable:    []string{""},
+			AttributeTable: []otlpKeyValueAndUnit{{}},
+			StackTable:     []otlpStack{{}},
+		},
+		strings:    map[string]int32{"": 0},
+		stacks:     map[string]int32{"": 0},
+		attributes: map[string]int32{},
+		links:      map[otlpLink]int32{},
+	}
+}
+
+func (d *dictionary) str(s string) int32 {
+	i, ok := d.strings[s]
+	if !ok {
+		i = int32(len(d.StringTable))
+		d.strings[s] = i
+		d.StringTable = append(d.StringTable, s)
+	}
+	return i
+}
+
+func (d *dictionary) stack(locations []int32) int32 {
+	var key strings.Builder
+	for _, l := range locations {
+		key.WriteString(strconv.Itoa(int(l)))
+		key.WriteByte(',')
+	}
+	i, ok := d.stacks[key.String()]
+	if !ok {
+		i = int32(len(d.StackTable))
+		d.stacks[key.String()] = i
+		d.StackTable = append(d.StackTable, otlpStack{LocationIndices: locations})
+	}
+	return i
+}
+
+func (d *dictionary) attribute(key string, value otlpAnyValue, unit string) int32 {
+	a := otlpKeyValueAndUnit{KeyStrindex: d.str(key), Value: value, UnitStrindex: d.str(unit)}
+	id := strconv.Itoa(int(a.KeyStrindex)) + "," + strconv.Itoa(int(a.UnitStrindex)) + ",i" + value.IntValue
+	if value.StringValue != nil {
+		id = strconv.Itoa(int(a.KeyStrindex)) + "," + strconv.Itoa(int(a.UnitStrindex)) + ",s" + *value.StringValue
+	}
+	i, ok := d.attributes[id]
+	if !ok {
+		i = int32(len(d.AttributeTable))
+		d.attributes[id] = i
+		d.AttributeTable = append(d.AttributeTable, a)
+	}
+	return i
+}
+
+// link returns the index of the link to the span, 0 if the IDs are not valid.
+func (d *dictionary) link(traceID, spanID string) int32 {
+	if !validHexID(traceID, 16) || !validHexID(spanID, 8) {
+		return 0
+	}
+	l := otlpLink{TraceID: strings.ToLower(traceID), SpanID: strings.ToLower(spanID)}
+	i, ok := d.links[l]
+	if !ok {
+		i = int32(len(d.LinkTable))
+		d.links[l] = i
+		d.LinkTable = append(d.LinkTable, l)
+	}
+	return i
+}
+
+// validHexID reports whether id is the hex encoding of n bytes, not all zero.
+func validHexID(id string, n int) bool {
+	b, err := hex.DecodeString(id)
+	if err != nil || len(b) != n {
+		return false
+	}
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+func TestOTLP(t *testing.T) {
+	cpu := testProfile([]*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		func(handler, serve *profile.Location) []*profile.Sample {
+			return []*profile.Sample{
+				{
+					Location: []*profile.Location{handler, serve},
+					Value:    []int64{2, 20000000},
+					Label: map[string][]string{
+						"http.route": {"/users/:id"},
+						"trace_id":   {"0102030405060708090a0b0c0d0e0f10"},
+						"span_id":    {"0102030405060708"},
+					},
+				},
+				{
+					Location: []*profile.Location{serve},
+					Value:    []int64{1, 10000000},
+					Label:    map[string][]string{"http.route": {"/users/:id"}, "trace_id": {"invalid"}},
+					NumLabel: map[string][]int64{"bytes": {512}},
+					NumUnit:  map[string][]string{"bytes": {"bytes"}},
+				},
+			}
+		})
+	cpu.PeriodType = &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}
+	cpu.Period = 10000000
+	cpu.DurationNanos = int64(10 * time.Second)
+	data := encodeProfile(t, cpu)
+
+	var req otlpRequest
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.DeepEqual(t, otlpProfilesPath, r.URL.Path)
+		assert.DeepEqual(t, "application/json", r.Header.Get("Content-Type"))
+		assert.DeepEqual(t, "secret", r.Header.Get("Api-Key"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	o := NewOTLP(srv.URL, "app",
+		WithOTLPResourceAttributes(map[string]string{"deployment.environment": "prod"}),
+		WithOTLPHeader("Api-Key", "secret"),
+	)
+	startedAt := time.Unix(1700000000, 0)
+	err := o.Export(context.Background(), store.Profile{
+		Type:      "cpu",
+		Source:    "request",
+		StartedAt: startedAt,
+		Labels:    map[string]string{"http.method": "GET"},
+	}, data)
+	assert.Nil(t, err)
+
+	d := req.Dictionary
+	str := func(i int32) string { return d.StringTable[i] }
+
+	resource := map[string]string{}
+	for _, kv := range req.ResourceProfiles[0].Resource.Attributes {
+		resource[kv.Key] = *kv.Value.StringValue
+	}
+	assert.DeepEqual(t, "app", resource["service.name"])
+	assert.DeepEqual(t, "prod", resource["deployment.environment"])
+	assert.DeepEqual(t, "go", resource["process.runtime.name"])
+
+	// one profile per sample type
+	profiles := req.ResourceProfiles[0].ScopeProfiles[0].Profiles
+	assert.DeepEqual(t, 2, len(profiles))
+	assert.DeepEqual(t, "samples", str(profiles[0].SampleType.TypeStrindex))
+	assert.DeepEqual(t, "nanoseconds", str(profiles[1].SampleType.UnitStrindex))
+	assert.DeepEqual(t, "1700000000000000000", profiles[0].TimeUnixNano)
+	assert.DeepEqual(t, "10000000000", profiles[0].DurationNano)
+	assert.DeepEqual(t, "10000000", profiles[0].Period)
+	assert.DeepEqual(t, 32, len(profiles[0].ProfileID))
+	assert.True(t, profiles[0].ProfileID != profiles[1].ProfileID)
+
+	attributes := map[string]string{}
+	for _, i := range profiles[0].AttributeIndices {
+		attributes[str(d.AttributeTable[i].KeyStrindex)] = *d.AttributeTable[i].Value.StringValue
+	}
+	assert.DeepEqual(t, map[string]string{"profile.type": "cpu", "profile.source": "request", "http.method": "GET"}, attributes)
+
+	first, second := profiles[1].Samples[0], profiles[1].Samples[1]
+	assert.DeepEqual(t, []string{"20000000"}, first.Values)
+	assert.DeepEqual(t, []string{"1"}, profiles[0].Samples[1].Values)
+
+	// the stack, from the leaf
+	var stack []string
+	for _, l := range d.StackTable[first.StackIndex].LocationIndices {
+		loc := d.LocationTable[l]
+		assert.DeepEqual(t, "/bin/app", str(d.MappingTable[loc.MappingIndex].FilenameStrindex))
+		stack = append(stack, str(d.FunctionTable[loc.Lines[0].FunctionIndex].NameStrindex)+":"+loc.Lines[0].Line)
+	}
+	assert.DeepEqual(t, []string{"main.handler:12", "main.serve:25"}, stack)
+
+	// the trace labels link the sample to its span, the other labels are attributes
+	assert.DeepEqual(t, otlpLink{TraceID: "0102030405060708090a0b0c0d0e0f10", SpanID: "0102030405060708"}, d.LinkTable[first.LinkIndex])
+	assert.DeepEqual(t, int32(0), second.LinkIndex)
+	assert.DeepEqual(t, 1, len(first.AttributeIndices))
+	assert.DeepEqual(t, "http.route", str(d.AttributeTable[first.AttributeIndices[0]].KeyStrindex))
+	assert.DeepEqual(t, first.AttributeIndices[0], second.AttributeIndices[0])
+	bytesLabel := d.AttributeTable[second.AttributeIndices[1]]
+	assert.DeepEqual(t, "bytes", str(bytesLabel.KeyStrindex))
+	assert.DeepEqual(t, "512", bytesLabel.Value.IntValue)
+	assert.DeepEqual(t, "bytes", str(bytesLabel.UnitStrindex))
+
+	// invalid profiles are not retried
+	err = o.Export(context.Background(), store.Profile{Type: "cpu"}, []byte("invalid"))
+	assert.NotNil(t, err)
+	assert.False(t, retryable(err))
+
+	status = http.StatusServiceUnavailable
+	err = o.Export(context.Background(), store.Profile{Type: "cpu"}, data)
+	assert.True(t, retryable(err))
+}
+
+func TestOTLPMultiValuedLabel(t *testing.T) {
+	p := testProfile([]*profile.ValueType{{Type: "samples", Unit: "count"}},
+		func(handler, serve *profile.Location) []*profile.Sample {
+			return []*profile.Sample{{
+				Location: []*profile.Location{handler, serve},
+				Value:    []int64{1},
+				Label:    map[string][]string{"tag": {"a", "b"}},
+			}}
+		})
+
+	req := NewOTLP("http://localhost:4318", "app").convert(store.Profile{Type: "cpu"}, p)
+
+	d := req.Dictionary
+	var values []string
+	for _, i := range req.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0].AttributeIndices {
+		values = append(values, d.StringTable[d.AttributeTable[i].KeyStrindex]+"="+*d.AttributeTable[i].Value.StringValue)
+	}
+	assert.DeepEqual(t, []string{"tag=a", "tag=b"}, values)
+}
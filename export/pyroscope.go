@@ -0,0 +1,352 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// sampleTypeConfig describes a sample type of a pprof profile to Pyroscope.
+type sampleTypeConfig struct {
+	Units       string `json:"units,omitempty"`
+	Aggregation string `json:"aggregation,omitempty"`
+	DisplayName string `json:"display-name,omitempty"`
+	Sampled     bool   `json:"sampled,omitempty"`
+}
+
+var (
+	heapSampleTypes = map[string]sampleTypeConfig{
+		"alloc_objects": {Units: "objects"},
+		"alloc_space":   {Units: "bytes"},
+		"inuse_objects": {Units: "objects", Aggregation: "average"},
+		"inuse_space":   {Units: "bytes", Aggregation: "average"},
+	}
+
+	// pyroscopeSampleTypes are the sample types of the profiles supported by Pyroscope,
+	// keyed by the type of profile.
+	pyroscopeSampleTypes = map[string]map[string]sampleTypeConfig{
+		"cpu": {
+			"cpu": {Units: "samples", Sampled: true},
+		},
+		"heap":   heapSampleTypes,
+		"allocs": heapSampleTypes,
+		"goroutine": {
+			"goroutine": {DisplayName: "goroutines", Units: "goroutines", Aggregation: "average"},
+		},
+		"mutex": {
+			"contentions": {DisplayName: "mutex_count", Units: "lock_samples"},
+			"delay":       {DisplayName: "mutex_duration", Units: "lock_nanoseconds"},
+		},
+		"block": {
+			"contentions": {DisplayName: "block_count", Units: "lock_samples"},
+			"delay":       {DisplayName: "block_duration", Units: "lock_nanoseconds"},
+		},
+		"fgprof": {
+			"samples": {DisplayName: "wall", Units: "samples", Sampled: true},
+			"time":    {DisplayName: "wall_time", Units: "nanoseconds"},
+		},
+	}
+
+	heapCumulativeSampleTypes = map[string]bool{"alloc_objects": true, "alloc_space": true}
+
+	// pyroscopeCumulativeSampleTypes are the sample types the runtime cumulates since the
+	// process started, while Pyroscope expects what happened since the previous upload,
+	// keyed by the type of profile.
+	pyroscopeCumulativeSampleTypes = map[string]map[string]bool{
+		"heap":   heapCumulativeSampleTypes,
+		"allocs": heapCumulativeSampleTypes,
+		"mutex":  {"contentions": true, "delay": true},
+		"block":  {"contentions": true, "delay": true},
+	}
+)
+
+// PyroscopeOption configures a Pyroscope exporter.
+type PyroscopeOption func(p *Pyroscope)
+
+// WithPyroscopeTags adds tags to every exported profile, on top of the source and the
+// labels of the profile.
+func WithPyroscopeTags(tags map[string]string) PyroscopeOption {
+	return func(p *Pyroscope) {
+		for k, v := range tags {
+			p.tags[k] = v
+		}
+	}
+}
+
+// WithPyroscopeHeader sets a header of every request, e.g. "Authorization" for an
+// authenticated backend.
+func WithPyroscopeHeader(key, value string) PyroscopeOption {
+	return func(p *Pyroscope) {
+		p.header.Set(key, value)
+	}
+}
+
+// WithPyroscopeClient sets the client sending the profiles, http.DefaultClient by default.
+func WithPyroscopeClient(c *http.Client) PyroscopeOption {
+	return func(p *Pyroscope) {
+		p.client = c
+	}
+}
+
+// Pyroscope is an Exporter pushing the profiles to the /ingest API of a Pyroscope
+// compatible backend, in the pprof format. It supports the cpu, heap, allocs, goroutine,
+// mutex, block and fgprof profiles, the latter as wall-clock time.
+//
+// The allocation, contention and delay sample types of the heap, allocs, mutex and block
+// profiles are cumulated since the process started, they are exported as the difference
+// with the previous profile of the same series, i.e. of the same type and tags. The first
+// profile of such a series is only kept as the baseline of the next one.
+type Pyroscope struct {
+	url     string
+	appName string
+	tags    map[string]string
+	header  http.Header
+	client  *http.Client
+
+	mu        sync.Mutex
+	baselines map[string]baseline
+}
+
+// baseline is the last profile exported of a series of cumulative profiles.
+type baseline struct {
+	startedAt time.Time
+	profile   *profile.Profile
+}
+
+// NewPyroscope returns a Pyroscope exporter sending the profiles of the application
+// appName to the backend at serverURL, e.g. "http://pyroscope:4040".
+func NewPyroscope(serverURL, appName string, opts ...PyroscopeOption) *Pyroscope {
+	p := &Pyroscope{
+		url:       strings.TrimSuffix(serverURL, "/") + "/ingest",
+		appName:   appName,
+		tags:      map[string]string{},
+		header:    http.Header{},
+		client:    http.DefaultClient,
+		baselines: map[string]baseline{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Export implements Exporter.
+func (p *Pyroscope) Export(ctx context.Context, profile store.Profile, data []byte) error {
+	sampleTypes, ok := pyroscopeSampleTypes[profile.Type]
+	if !ok {
+		return nil
+	}
+	name := p.name(profile)
+	from, until := profile.StartedAt, profile.StartedAt.Add(profile.Duration)
+
+	key := profile.Type + " " + name
+	var next *baseline
+	if cumulative, ok := pyroscopeCumulativeSampleTypes[profile.Type]; ok {
+		current, err := parseProfile(data)
+		if err != nil {
+			return err
+		}
+		next = &baseline{startedAt: profile.StartedAt, profile: current}
+		p.mu.Lock()
+		previous, ok := p.baselines[key]
+		if !ok {
+			p.baselines[key] = *next
+		}
+		p.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		if data, err = deltaSince(previous.profile, current, cumulative); err != nil {
+			return permanent(err)
+		}
+		from = previous.startedAt
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(data); err != nil {
+		return err
+	}
+	part, err = w.CreateFormFile("sample_type_config", "sample_type_config.json")
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(part).Encode(sampleTypes); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"name":       {name},
+		"from":       {strconv.FormatInt(from.Unix(), 10)},
+		"until":      {strconv.FormatInt(until.Unix(), 10)},
+		"format":     {"pprof"},
+		"spyName":    {"gospy"},
+		"sampleRate": {"100"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	for k, v := range p.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	io.Copy(io.Discard, resp.Body) // nolint:errcheck
+
+	if next != nil {
+		// the next profile of the series is exported as the difference with this one
+		p.mu.Lock()
+		p.baselines[key] = *next
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// parseProfile parses a pprof profile, which is not worth retrying if it is invalid.
+func parseProfile(data []byte) (*profile.Profile, error) {
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, permanent(err)
+	}
+	return p, nil
+}
+
+// deltaSince returns current minus previous in the pprof format for the cumulative sample
+// types, the other ones being those of current.
+func deltaSince(previous, current *profile.Profile, cumulative map[string]bool) ([]byte, error) {
+	previous = previous.Copy()
+	ratios := make([]float64, len(previous.SampleType))
+	for i, st := range previous.SampleType {
+		if cumulative[st.Type] {
+			ratios[i] = -1
+		}
+	}
+	if err := previous.ScaleN(ratios); err != nil {
+		return nil, err
+	}
+	delta, err := profile.Merge([]*profile.Profile{previous, current})
+	if err != nil {
+		return nil, err
+	}
+	// drop the stacks which did not change
+	samples := delta.Sample[:0]
+	for _, s := range delta.Sample {
+		for _, v := range s.Value {
+			if v != 0 {
+				samples = append(samples, s)
+				break
+			}
+		}
+	}
+	delta.Sample = samples
+	delta.TimeNanos, delta.DurationNanos = current.TimeNanos, current.TimeNanos-previous.TimeNanos
+
+	var buf bytes.Buffer
+	if err := delta.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// name returns the name of the profile in Pyroscope, the application name followed by
+// the tags, e.g. "app{http_route=/users/:id,source=request}".
+func (p *Pyroscope) name(profile store.Profile) string {
+	tags := make(map[string]string, len(p.tags)+len(profile.Labels)+1)
+	for k, v := range profile.Labels {
+		tags[pyroscopeTagKey(k)] = pyroscopeTagValue(v)
+	}
+	if profile.Source != "" {
+		tags["source"] = pyroscopeTagValue(profile.Source)
+	}
+	for k, v := range p.tags {
+		tags[pyroscopeTagKey(k)] = pyroscopeTagValue(v)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(p.appName)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// pyroscopeTagKey replaces the characters Pyroscope does not allow in tag keys by '_',
+// e.g. "http.route" becomes "http_route".
+func pyroscopeTagKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+// pyroscopeTagValue replaces the characters delimiting the tags by '_'.
+func pyroscopeTagValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '=', '{', '}':
+			return '_'
+		}
+		return r
+	}, v)
+}
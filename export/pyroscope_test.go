@@ -0,0 +1,159 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+func TestPyroscope(t *testing.T) {
+	var requests []*http.Request
+	var profiles []string
+	var configs []map[string]sampleTypeConfig
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		f, _, err := r.FormFile("profile")
+		assert.Nil(t, err)
+		b, _ := io.ReadAll(f)
+		profiles = append(profiles, string(b))
+
+		f, _, err = r.FormFile("sample_type_config")
+		assert.Nil(t, err)
+		var config map[string]sampleTypeConfig
+		assert.Nil(t, json.NewDecoder(f).Decode(&config))
+		configs = append(configs, config)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := NewPyroscope(srv.URL+"/", "app",
+		WithPyroscopeTags(map[string]string{"env": "prod", "region": "eu,west"}),
+		WithPyroscopeHeader("Authorization", "Bearer token"),
+	)
+	startedAt := time.Unix(1700000000, 0)
+	err := p.Export(context.Background(), store.Profile{
+		ID:        "id",
+		Type:      "cpu",
+		Source:    "request",
+		StartedAt: startedAt,
+		Duration:  10 * time.Second,
+		Labels:    map[string]string{"http.route": "/users/:id"},
+	}, []byte("cpu profile"))
+	assert.Nil(t, err)
+
+	assert.DeepEqual(t, 1, len(requests))
+	r := requests[0]
+	assert.DeepEqual(t, "/ingest", r.URL.Path)
+	assert.DeepEqual(t, "Bearer token", r.Header.Get("Authorization"))
+	assert.DeepEqual(t, "app{env=prod,http_route=/users/:id,region=eu_west,source=request}", r.URL.Query().Get("name"))
+	assert.DeepEqual(t, "1700000000", r.URL.Query().Get("from"))
+	assert.DeepEqual(t, "1700000010", r.URL.Query().Get("until"))
+	assert.DeepEqual(t, "pprof", r.URL.Query().Get("format"))
+	assert.DeepEqual(t, "cpu profile", profiles[0])
+	assert.DeepEqual(t, pyroscopeSampleTypes["cpu"], configs[0])
+
+	assert.Nil(t, p.Export(context.Background(), store.Profile{Type: "fgprof", StartedAt: startedAt}, []byte("fgprof profile")))
+	assert.DeepEqual(t, "wall", configs[1]["samples"].DisplayName)
+	assert.DeepEqual(t, "app{env=prod,region=eu_west}", requests[1].URL.Query().Get("name"))
+
+	// unsupported profiles are skipped
+	assert.Nil(t, p.Export(context.Background(), store.Profile{Type: "threadcreate"}, nil))
+	assert.DeepEqual(t, 2, len(requests))
+
+	status = http.StatusBadRequest
+	err = p.Export(context.Background(), store.Profile{Type: "goroutine"}, nil)
+	assert.DeepEqual(t, &HTTPError{StatusCode: http.StatusBadRequest}, err)
+	assert.False(t, retryable(err))
+}
+
+func TestPyroscopeCumulative(t *testing.T) {
+	var requests []*http.Request
+	var profiles []*profile.Profile
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		f, _, err := r.FormFile("profile")
+		assert.Nil(t, err)
+		p, err := profile.Parse(f)
+		assert.Nil(t, err)
+		profiles = append(profiles, p)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := NewPyroscope(srv.URL, "app")
+	startedAt := time.Unix(1700000000, 0)
+	heapProfile := func(ts time.Time, values ...int64) []byte {
+		p := testProfile([]*profile.ValueType{
+			{Type: "alloc_objects", Unit: "count"},
+			{Type: "alloc_space", Unit: "bytes"},
+			{Type: "inuse_objects", Unit: "count"},
+			{Type: "inuse_space", Unit: "bytes"},
+		}, func(handler, serve *profile.Location) []*profile.Sample {
+			return []*profile.Sample{{Location: []*profile.Location{handler, serve}, Value: values}}
+		})
+		p.TimeNanos = ts.UnixNano()
+		return encodeProfile(t, p)
+	}
+	heap := func(d time.Duration, allocObjects, allocSpace, inuseObjects, inuseSpace int64) error {
+		return p.Export(context.Background(), store.Profile{Type: "heap", Source: "continuous", StartedAt: startedAt.Add(d)},
+			heapProfile(startedAt.Add(d), allocObjects, allocSpace, inuseObjects, inuseSpace))
+	}
+
+	// the first profile of the series is the baseline of the next one
+	assert.Nil(t, heap(0, 10, 1000, 5, 500))
+	assert.DeepEqual(t, 0, len(requests))
+
+	// the allocations are the ones since the previous upload, the in-use values as captured
+	assert.Nil(t, heap(time.Minute, 15, 1600, 4, 400))
+	assert.DeepEqual(t, 1, len(requests))
+	assert.DeepEqual(t, "1700000000", requests[0].URL.Query().Get("from"))
+	assert.DeepEqual(t, "1700000060", requests[0].URL.Query().Get("until"))
+	assert.DeepEqual(t, 1, len(profiles[0].Sample))
+	assert.DeepEqual(t, []int64{5, 600, 4, 400}, profiles[0].Sample[0].Value)
+
+	// a failed upload does not move the baseline
+	status = http.StatusServiceUnavailable
+	assert.NotNil(t, heap(2*time.Minute, 20, 2000, 4, 400))
+	status = http.StatusOK
+	assert.Nil(t, heap(3*time.Minute, 30, 3000, 6, 600))
+	assert.DeepEqual(t, 3, len(requests))
+	assert.DeepEqual(t, "1700000060", requests[2].URL.Query().Get("from"))
+	assert.DeepEqual(t, []int64{15, 1400, 6, 600}, profiles[2].Sample[0].Value)
+
+	// the series are kept apart
+	err := p.Export(context.Background(), store.Profile{Type: "heap", Source: "watcher", StartedAt: startedAt},
+		heapProfile(startedAt, 100, 10000, 1, 100))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 3, len(requests))
+
+	err = p.Export(context.Background(), store.Profile{Type: "mutex"}, []byte("not a profile"))
+	assert.NotNil(t, err)
+	assert.False(t, retryable(err))
+}
@@ -42,18 +42,53 @@
 package pprof
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/cloudwego/hertz/pkg/route"
 	"github.com/felixge/fgprof"
-
-	"github.com/hertz-contrib/pprof/adaptor"
 )
 
 const (
 	// DefaultFgprofPrefix url prefix of fgprof
 	DefaultFgprofPrefix = "/debug/fgprof"
+
+	// fgprofDefaultSeconds is how long fgprof samples unless asked otherwise.
+	fgprofDefaultSeconds = 30
 )
 
+// fgprofHandler is fgprof.Handler, but stops sampling once the request is canceled,
+// e.g. when the client goes away.
+func fgprofHandler(w http.ResponseWriter, r *http.Request) {
+	var seconds int
+	var err error
+	if s := r.URL.Query().Get("seconds"); s == "" {
+		seconds = fgprofDefaultSeconds
+	} else if seconds, err = strconv.Atoi(s); err != nil || seconds <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad seconds: %d: %s\n", seconds, err)
+		return
+	}
+
+	format := fgprof.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		format = fgprof.FormatPprof
+	}
+
+	stop := fgprof.Start(w, format)
+	defer stop() // nolint:errcheck
+
+	timer := time.NewTimer(time.Duration(seconds) * time.Second)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
 func getFgprofPrefix(prefixOptions ...string) string {
 	prefix := DefaultFgprofPrefix
 	if len(prefixOptions) > 0 {
@@ -62,15 +97,33 @@ func getFgprofPrefix(prefixOptions ...string) string {
 	return prefix
 }
 
+// FgprofRegister registers the fgprof handler with the provided hertz.Hertz.
+// prefixOptions is a optional. If not prefixOptions, the default path prefix is used,
+// otherwise first prefixOptions will be path prefix.
 func FgprofRegister(r *server.Hertz, prefixOptions ...string) {
 	FgprofRouteRegister(&(r.RouterGroup), prefixOptions...)
 }
 
+// FgprofRouteRegister registers the fgprof handler with the provided hertz.RouterGroup.
+// prefixOptions is a optional. If not prefixOptions, the default path prefix is used,
+// otherwise first prefixOptions will be path prefix.
 func FgprofRouteRegister(rg *route.RouterGroup, prefixOptions ...string) {
-	prefix := getFgprofPrefix(prefixOptions...)
+	FgprofRouteRegisterWithOptions(rg, WithPrefix(getFgprofPrefix(prefixOptions...)))
+}
+
+// FgprofRegisterWithOptions registers the fgprof handler with the provided hertz.Hertz,
+// configured by opts.
+func FgprofRegisterWithOptions(r *server.Hertz, opts ...Option) {
+	FgprofRouteRegisterWithOptions(&(r.RouterGroup), opts...)
+}
+
+// FgprofRouteRegisterWithOptions registers the fgprof handler with the provided
+// hertz.RouterGroup, configured by opts.
+func FgprofRouteRegisterWithOptions(rg *route.RouterGroup, opts ...Option) {
+	o := newOptions(DefaultFgprofPrefix, opts...)
 
-	prefixRouter := rg.Group(prefix)
+	prefixRouter := rg.Group(o.prefix, o.groupMiddlewares()...)
 	{
-		prefixRouter.GET("/", adaptor.NewHertzHTTPHandlerFunc(fgprof.Handler().ServeHTTP))
+		prefixRouter.GET("/", o.expensiveHandlers("fgprof", fgprofDefaultSeconds, streamHandler(fgprofHandler))...)
 	}
 }
@@ -140,3 +140,32 @@ func Test_Fgprof_Other(t *testing.T) {
 	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/fpprof/302", nil)
 	assert.DeepEqual(t, http.StatusNotFound, resp.Code)
 }
+
+func Test_Fgprof_With_Options(t *testing.T) {
+	called := 0
+	h := server.New()
+	FgprofRegisterWithOptions(h,
+		WithPrefix("/admin/fgprof"),
+		WithMiddleware(func(c context.Context, ctx *app.RequestContext) {
+			called++
+			ctx.Next(c)
+		}),
+	)
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/admin/fgprof/?seconds=1", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	assert.DeepEqual(t, 1, called)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/fgprof/", nil)
+	assert.DeepEqual(t, http.StatusNotFound, resp.Code)
+}
+
+func Test_Fgprof_Expensive_Middleware(t *testing.T) {
+	h := server.New()
+	FgprofRegisterWithOptions(h, WithExpensiveMiddleware(func(c context.Context, ctx *app.RequestContext) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/fgprof/", nil)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2009 The Go Authors.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *    * Redistributions of source code must retain the above copyright
+ * notice, this list of conditions and the following disclaimer.
+ *    * Redistributions in binary form must reproduce the above
+ * copyright notice, this list of conditions and the following disclaimer
+ * in the documentation and/or other materials provided with the
+ * distribution.
+ *    * Neither the name of Google LLC nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+ * A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+ * LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+ * DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+ * THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ * This file may have been modified by CloudWeGo authors. All CloudWeGo
+ * Modifications are Copyright 2023 CloudWeGo Authors.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"runtime/pprof"
+	"sort"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+var profileDescriptions = map[string]string{
+	"allocs":       "A sampling of all past memory allocations",
+	"block":        "Stack traces that led to blocking on synchronization primitives",
+	"cmdline":      "The command line invocation of the current program",
+	"diff":         "Difference between two stored profiles, or a stored profile and now. You can specify them by id or RFC 3339 timestamp with type in the base and target GET parameters, and get the top regressions with format=json.",
+	"goroutine":    "Stack traces of all current goroutines. Use debug=2 as a query parameter to export in the same format as an unrecovered panic.",
+	"heap":         "A sampling of memory allocations of live objects. You can specify the gc GET parameter to run GC before taking the heap sample.",
+	"mutex":        "Stack traces of holders of contended mutexes",
+	"profiles":     "Profiles kept in the store, e.g. the ones of profiled requests. You can filter them by the type and source GET parameters, and download one of them from profiles/{id}.",
+	"profile":      "CPU profile. You can specify the duration in the seconds GET parameter. After you get the profile file, use the go tool pprof command to investigate the profile.",
+	"symbol":       "Maps given program counters to function names. Counters can be specified in a GET raw query or POST body, multiple counters are separated by '+'.",
+	"threadcreate": "Stack traces that led to the creation of new OS threads",
+	"trace":        "A trace of execution of the current program. You can specify the duration in the seconds GET parameter. After you get the trace file, use the go tool trace command to investigate the trace.",
+}
+
+type profileEntry struct {
+	Name  string
+	Href  string
+	Desc  string
+	Count int
+}
+
+// newIndexHandler returns the index page in the same layout as net/http/pprof.Index,
+// but only listing the given endpoints. With the format=json query parameter, it replies
+// them in a machine-readable form instead, see serveDiscovery, delta telling whether the
+// cumulative profiles are served as delta profiles too, and fgprofPaths where fgprof is
+// mounted.
+func newIndexHandler(endpoints []endpoint, delta bool, fgprofPaths []string) app.HandlerFunc {
+	mounted := make(map[string]bool, len(endpoints))
+	for _, e := range endpoints {
+		mounted[e.name] = true
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		if c.Query("format") == "json" {
+			serveDiscovery(c, string(c.Request.URI().Path()), mounted, delta, fgprofPaths)
+			return
+		}
+
+		var profiles []profileEntry
+		for _, p := range pprof.Profiles() {
+			if !mounted[p.Name()] {
+				continue
+			}
+			profiles = append(profiles, profileEntry{
+				Name:  p.Name(),
+				Href:  p.Name(),
+				Desc:  profileDescriptions[p.Name()],
+				Count: p.Count(),
+			})
+		}
+
+		// Adding other profiles exposed from within this package
+		for _, p := range []string{"cmdline", "diff", "profile", "profiles", "symbol", "trace"} {
+			if !mounted[p] {
+				continue
+			}
+			profiles = append(profiles, profileEntry{
+				Name: p,
+				Href: p,
+				Desc: profileDescriptions[p],
+			})
+		}
+
+		sort.Slice(profiles, func(i, j int) bool {
+			return profiles[i].Name < profiles[j].Name
+		})
+
+		c.Response.Header.Set("X-Content-Type-Options", "nosniff")
+		c.Data(consts.StatusOK, "text/html; charset=utf-8",
+			indexTmplExecute(string(c.Request.URI().Path()), profiles, mounted["goroutine"]))
+	}
+}
+
+func indexTmplExecute(title string, profiles []profileEntry, goroutineDump bool) []byte {
+	var b bytes.Buffer
+	title = html.EscapeString(title)
+	fmt.Fprintf(&b, `<html>
+<head>
+<title>%s</title>
+<style>
+.profile-name{
+	display:inline-block;
+	width:6rem;
+}
+</style>
+</head>
+<body>
+%s
+<br>
+<p>Set debug=1 as a query parameter to export in legacy text format</p>
+<br>
+Types of profiles available:
+<table>
+<thead><td>Count</td><td>Profile</td></thead>
+`, title, title)
+
+	for _, profile := range profiles {
+		link := &url.URL{Path: profile.Href, RawQuery: "debug=1"}
+		fmt.Fprintf(&b, "<tr><td>%d</td><td><a href='%s'>%s</a></td></tr>\n", profile.Count, link, html.EscapeString(profile.Name))
+	}
+
+	b.WriteString("</table>\n")
+	if goroutineDump {
+		b.WriteString("<a href=\"goroutine?debug=2\">full goroutine stack dump</a>\n")
+	}
+	b.WriteString(`<br>
+<p>
+Profile Descriptions:
+<ul>
+`)
+	for _, profile := range profiles {
+		fmt.Fprintf(&b, "<li><div class=profile-name>%s: </div> %s</li>\n", html.EscapeString(profile.Name), html.EscapeString(profile.Desc))
+	}
+	b.WriteString(`</ul>
+</p>
+</body>
+</html>`)
+
+	return b.Bytes()
+}
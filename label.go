@@ -0,0 +1,91 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+const (
+	// RouteLabel is the pprof label of the route of the request, as registered, e.g. "/users/:id".
+	RouteLabel = "http.route"
+	// MethodLabel is the pprof label of the method of the request.
+	MethodLabel = "http.method"
+)
+
+// LabelOption configures the middleware returned by LabelMiddleware.
+type LabelOption func(o *labelOptions)
+
+type labelOptions struct {
+	headers []labelSource
+	params  []labelSource
+}
+
+// labelSource is a value of the request set as a pprof label.
+type labelSource struct {
+	name  string
+	label string
+}
+
+// WithHeaderLabel sets the value of the request header as the label, e.g. "X-Tenant" as "tenant".
+func WithHeaderLabel(header, label string) LabelOption {
+	return func(o *labelOptions) {
+		o.headers = append(o.headers, labelSource{header, label})
+	}
+}
+
+// WithParamLabel sets the value of the route parameter as the label, e.g. "id" of "/users/:id".
+func WithParamLabel(param, label string) LabelOption {
+	return func(o *labelOptions) {
+		o.params = append(o.params, labelSource{param, label})
+	}
+}
+
+// LabelMiddleware returns a middleware running the rest of the chain with pprof labels:
+// RouteLabel, MethodLabel and the ones set by the options, so that the CPU and goroutine
+// profiles can be broken down by route, e.g. with go tool pprof -tagfocus http.route=/users/:id.
+// Labels with an empty value are left out. Goroutines started by the handlers inherit them.
+func LabelMiddleware(opts ...LabelOption) app.HandlerFunc {
+	o := &labelOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		labels := make([]string, 0, 4+2*(len(o.headers)+len(o.params)))
+		add := func(label, value string) {
+			if value != "" {
+				labels = append(labels, label, value)
+			}
+		}
+		add(RouteLabel, c.FullPath())
+		add(MethodLabel, string(c.Method()))
+		for _, h := range o.headers {
+			add(h.label, string(c.GetHeader(h.name)))
+		}
+		for _, p := range o.params {
+			add(p.label, c.Param(p.name))
+		}
+
+		pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+			c.Next(ctx)
+		})
+	}
+}
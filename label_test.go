@@ -0,0 +1,59 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func Test_Label_Middleware(t *testing.T) {
+	h := server.New()
+	h.Use(LabelMiddleware(WithHeaderLabel("X-Tenant", "tenant"), WithParamLabel("id", "user_id")))
+
+	called := 0
+	h.GET("/users/:id", func(ctx context.Context, c *app.RequestContext) {
+		called++
+		labels := map[string]string{}
+		pprof.ForLabels(ctx, func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+		if c.Query("tenant") == "" {
+			assert.DeepEqual(t, map[string]string{"http.route": "/users/:id", "http.method": "GET", "user_id": "42"}, labels)
+			return
+		}
+		assert.DeepEqual(t, map[string]string{"http.route": "/users/:id", "http.method": "GET", "user_id": "42", "tenant": "acme"}, labels)
+
+		// the labels of the goroutine are the ones of the profiles
+		var buf bytes.Buffer
+		assert.Nil(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+		assert.True(t, bytes.Contains(buf.Bytes(), []byte(`"http.route":"/users/:id"`)))
+	})
+
+	ut.PerformRequest(h.Engine, http.MethodGet, "/users/42", nil)
+	ut.PerformRequest(h.Engine, http.MethodGet, "/users/42?tenant=1", nil, ut.Header{Key: "X-Tenant", Value: "acme"})
+	assert.DeepEqual(t, 2, called)
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// maxDurationMiddleware rejects with 400 the captures called name asking for more than
+// max, or for a duration their handler would not parse as asked.
+func maxDurationMiddleware(name string, max time.Duration, defaultSeconds int) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		d, err := captureDuration(c, name, defaultSeconds)
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{
+				"error":       err.Error(),
+				"max_seconds": max.Seconds(),
+			})
+			return
+		}
+		if d > max {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{
+				"error":       fmt.Sprintf("seconds %g exceeds the maximum of %g", d.Seconds(), max.Seconds()),
+				"max_seconds": max.Seconds(),
+			})
+			return
+		}
+		c.Next(ctx)
+	}
+}
+
+// maxBuckets is the number of client buckets above which the full ones are dropped.
+const maxBuckets = 1024
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket rate limiter, one bucket per key.
+// A token is added every interval, up to burst.
+type rateLimiter struct {
+	every time.Duration
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(every time.Duration, burst int) *rateLimiter {
+	if every <= 0 || burst <= 0 {
+		panic("pprof: rate limit interval and burst must be positive")
+	}
+	return &rateLimiter{
+		every:   every,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket of key, or reports how long to wait for one.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+float64(now.Sub(b.last))/float64(l.every))
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(l.every))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets which are full by now, they are the same as a new one.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+float64(now.Sub(b.last))/float64(l.every) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects with 429 the captures exceeding the per client or the
+// global rate limit, either may be nil.
+func rateLimitMiddleware(client, global *rateLimiter, trustedProxies []string) app.HandlerFunc {
+	ips := newIPAllowlist(nil, trustedProxies)
+	return func(ctx context.Context, c *app.RequestContext) {
+		if client != nil {
+			key := ""
+			if ip := ips.clientIP(c); ip != nil {
+				key = ip.String()
+			}
+			if ok, wait := client.allow(key); !ok {
+				abortRateLimited(c, "client", wait)
+				return
+			}
+		}
+		if global != nil {
+			if ok, wait := global.allow(""); !ok {
+				abortRateLimited(c, "global", wait)
+				return
+			}
+		}
+		c.Next(ctx)
+	}
+}
+
+func abortRateLimited(c *app.RequestContext, scope string, wait time.Duration) {
+	c.Response.Header.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(consts.StatusTooManyRequests, utils.H{
+		"error":               scope + " rate limit exceeded",
+		"retry_after_seconds": wait.Seconds(),
+	})
+}
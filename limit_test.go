@@ -0,0 +1,120 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newRateLimiter(time.Second, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := l.allow("a")
+		assert.DeepEqual(t, true, ok)
+	}
+	ok, wait := l.allow("a")
+	assert.DeepEqual(t, false, ok)
+	assert.DeepEqual(t, time.Second, wait)
+
+	ok, _ = l.allow("b")
+	assert.DeepEqual(t, true, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, wait = l.allow("a")
+	assert.DeepEqual(t, false, ok)
+	assert.DeepEqual(t, 500*time.Millisecond, wait)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.allow("a")
+	assert.DeepEqual(t, true, ok)
+
+	now = now.Add(time.Hour)
+	l.prune(now)
+	assert.DeepEqual(t, 0, len(l.buckets))
+
+	assert.Panic(t, func() { newRateLimiter(0, 1) })
+}
+
+func Test_Max_Duration(t *testing.T) {
+	h := server.New()
+	opts := []Option{
+		WithMaxDuration("profile", 10*time.Second),
+		WithMaxDuration("fgprof", 10*time.Second),
+		WithMaxDuration("trace", 10*time.Second),
+	}
+	RegisterWithOptions(h, opts...)
+	FgprofRegisterWithOptions(h, opts...)
+
+	for _, target := range []string{
+		"/debug/pprof/profile?seconds=3600", "/debug/pprof/profile", "/debug/fgprof/", "/debug/pprof/trace?seconds=11",
+		// parsed as an integer by the profile handler, which would sample for 30 seconds
+		"/debug/pprof/profile?seconds=0.5", "/debug/fgprof/?seconds=0.5",
+		// overflowing a time.Duration
+		"/debug/pprof/profile?seconds=18446744083", "/debug/pprof/trace?seconds=1e300", "/debug/pprof/trace?seconds=NaN",
+	} {
+		resp := ut.PerformRequest(h.Engine, http.MethodGet, target, nil)
+		assert.DeepEqual(t, http.StatusBadRequest, resp.Code)
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		assert.DeepEqual(t, true, bytes.Contains(b, []byte(`"max_seconds":10`)))
+	}
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/trace?seconds=1", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	assert.Panic(t, func() { WithMaxDuration("heap", time.Second) })
+}
+
+func Test_Rate_Limit(t *testing.T) {
+	h := server.New()
+	RegisterWithOptions(h, WithClientRateLimit(time.Hour, 1))
+	RegisterWithOptions(h, WithPrefix("/global/pprof"), WithGlobalRateLimit(time.Hour, 2))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/trace?seconds=0.1", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/trace?seconds=0.1", nil)
+	assert.DeepEqual(t, http.StatusTooManyRequests, resp.Code)
+	retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, true, retryAfter > 3500)
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, true, bytes.Contains(b, []byte("client rate limit exceeded")))
+
+	// not a capture, not limited
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	for i := 0; i < 2; i++ {
+		resp = ut.PerformRequest(h.Engine, http.MethodGet, "/global/pprof/trace?seconds=0.1", nil)
+		assert.DeepEqual(t, http.StatusOK, resp.Code)
+	}
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/global/pprof/trace?seconds=0.1", nil)
+	assert.DeepEqual(t, http.StatusTooManyRequests, resp.Code)
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2009 The Go Authors.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *    * Redistributions of source code must retain the above copyright
+ * notice, this list of conditions and the following disclaimer.
+ *    * Redistributions in binary form must reproduce the above
+ * copyright notice, this list of conditions and the following disclaimer
+ * in the documentation and/or other materials provided with the
+ * distribution.
+ *    * Neither the name of Google LLC nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+ * A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+ * LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+ * DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+ * THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+ * (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ * This file may have been modified by CloudWeGo authors. All CloudWeGo
+ * Modifications are Copyright 2023 CloudWeGo Authors.
+ */
+
+package pprof
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// The handlers below are the ones of net/http/pprof written for hertz, mounted with
+// WithNativeHandlers. They reply the same bytes, but the body is held in the response.
+
+func nativeCmdline(ctx context.Context, c *app.RequestContext) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header(consts.HeaderContentType, "text/plain; charset=utf-8")
+	c.Response.SetBodyString(strings.Join(os.Args, "\x00"))
+}
+
+func nativeProfile(ctx context.Context, c *app.RequestContext) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	sec, err := strconv.ParseInt(string(c.FormValue("seconds")), 10, 64)
+	if sec <= 0 || err != nil {
+		sec = 30
+	}
+
+	c.Header(consts.HeaderContentType, "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="profile"`)
+	if err := rpprof.StartCPUProfile(c.Response.BodyWriter()); err != nil {
+		nativeServeError(c, consts.StatusInternalServerError,
+			fmt.Sprintf("Could not enable CPU profiling: %s", err))
+		return
+	}
+	nativeSleep(ctx, time.Duration(sec)*time.Second)
+	rpprof.StopCPUProfile()
+}
+
+func nativeTrace(ctx context.Context, c *app.RequestContext) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	sec, err := strconv.ParseFloat(string(c.FormValue("seconds")), 64)
+	if sec <= 0 || err != nil {
+		sec = 1
+	}
+
+	c.Header(consts.HeaderContentType, "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(c.Response.BodyWriter()); err != nil {
+		nativeServeError(c, consts.StatusInternalServerError,
+			fmt.Sprintf("Could not enable tracing: %s", err))
+		return
+	}
+	nativeSleep(ctx, time.Duration(sec*float64(time.Second)))
+	trace.Stop()
+}
+
+func nativeSymbol(ctx context.Context, c *app.RequestContext) {
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header(consts.HeaderContentType, "text/plain; charset=utf-8")
+
+	w := c.Response.BodyWriter()
+	// pprof only cares whether the number of symbols is 0 or not
+	fmt.Fprintf(w, "num_symbols: 1\n")
+
+	var b *bufio.Reader
+	if string(c.Method()) == consts.MethodPost {
+		b = bufio.NewReader(bytes.NewReader(c.Request.Body()))
+	} else {
+		b = bufio.NewReader(bytes.NewReader(c.URI().QueryString()))
+	}
+
+	for {
+		word, err := b.ReadSlice('+')
+		if err == nil {
+			word = word[0 : len(word)-1] // trim +
+		}
+		pc, _ := strconv.ParseUint(string(word), 0, 64)
+		if pc != 0 {
+			f := runtime.FuncForPC(uintptr(pc))
+			if f != nil {
+				fmt.Fprintf(w, "%#x %s\n", pc, f.Name())
+			}
+		}
+
+		// the last symbol has an error as it does not end with +
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(w, "reading request: %v\n", err)
+			}
+			break
+		}
+	}
+}
+
+// nativeHandler returns the handler of the named runtime/pprof profile.
+func nativeHandler(name string) app.HandlerFunc {
+	// delta profiles need the profile parser internal to net/http/pprof
+	delta := streamHandler(pprof.Handler(name).ServeHTTP)
+	return func(ctx context.Context, c *app.RequestContext) {
+		if len(c.FormValue("seconds")) > 0 {
+			delta(ctx, c)
+			return
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		p := rpprof.Lookup(name)
+		if p == nil {
+			nativeServeError(c, consts.StatusNotFound, "Unknown profile")
+			return
+		}
+		gc, _ := strconv.Atoi(string(c.FormValue("gc")))
+		if name == "heap" && gc > 0 {
+			runtime.GC()
+		}
+		debug, _ := strconv.Atoi(string(c.FormValue("debug")))
+		if debug != 0 {
+			c.Header(consts.HeaderContentType, "text/plain; charset=utf-8")
+		} else {
+			c.Header(consts.HeaderContentType, "application/octet-stream")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		}
+		p.WriteTo(c.Response.BodyWriter(), debug) // nolint:errcheck
+	}
+}
+
+func nativeSleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func nativeServeError(c *app.RequestContext, status int, txt string) {
+	c.Header(consts.HeaderContentType, "text/plain; charset=utf-8")
+	c.Header("X-Go-Pprof", "1")
+	c.Response.Header.Del("Content-Disposition")
+	c.Response.ResetBody()
+	c.SetStatusCode(status)
+	c.Response.AppendBodyString(txt + "\n")
+}
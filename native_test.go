@@ -0,0 +1,135 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/google/pprof/profile"
+)
+
+func Test_Native_Same_As_Adaptor(t *testing.T) {
+	adapted := server.New()
+	RegisterWithOptions(adapted, WithCoordinator(nil))
+	native := server.New()
+	RegisterWithOptions(native, WithCoordinator(nil), WithNativeHandlers())
+
+	pc := reflect.ValueOf(Test_Native_Same_As_Adaptor).Pointer()
+	symbols := strings.Join([]string{"0x" + strconv.FormatUint(uint64(pc), 16), "0x0", "0x" + strconv.FormatUint(uint64(pc), 16)}, "+")
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		sameBody bool
+		pprof    bool
+	}{
+		{"cmdline", http.MethodGet, "/debug/pprof/cmdline", "", true, false},
+		{"symbol get", http.MethodGet, "/debug/pprof/symbol?" + symbols, "", true, false},
+		{"symbol post", http.MethodPost, "/debug/pprof/symbol", symbols, true, false},
+		{"symbol none", http.MethodGet, "/debug/pprof/symbol", "", true, false},
+		{"heap", http.MethodGet, "/debug/pprof/heap", "", false, true},
+		{"heap gc", http.MethodGet, "/debug/pprof/heap?gc=1&debug=1", "", false, false},
+		{"goroutine debug", http.MethodGet, "/debug/pprof/goroutine?debug=2", "", false, false},
+		{"threadcreate", http.MethodGet, "/debug/pprof/threadcreate", "", false, true},
+		{"delta", http.MethodGet, "/debug/pprof/allocs?seconds=1", "", false, true},
+		{"bad delta", http.MethodGet, "/debug/pprof/allocs?seconds=-1", "", true, false},
+		{"profile", http.MethodGet, "/debug/pprof/profile?seconds=1", "", false, true},
+		{"trace", http.MethodGet, "/debug/pprof/trace?seconds=0.2", "", false, false},
+	}
+	for _, tt := range tests {
+		var body *ut.Body
+		if tt.body != "" {
+			body = &ut.Body{Body: bytes.NewReader([]byte(tt.body)), Len: len(tt.body)}
+		}
+		want := ut.PerformRequest(adapted.Engine, tt.method, tt.url, body)
+		if tt.body != "" {
+			body = &ut.Body{Body: bytes.NewReader([]byte(tt.body)), Len: len(tt.body)}
+		}
+		got := ut.PerformRequest(native.Engine, tt.method, tt.url, body)
+
+		if got.Code != want.Code {
+			t.Errorf("%q. status = %v, want %v", tt.name, got.Code, want.Code)
+		}
+		for _, h := range []string{"Content-Type", "Content-Disposition", "X-Content-Type-Options", "X-Go-Pprof"} {
+			if g, w := got.Header().Get(h), want.Header().Get(h); g != w {
+				t.Errorf("%q. %s = %q, want %q", tt.name, h, g, w)
+			}
+		}
+		if tt.sameBody && got.Body.String() != want.Body.String() {
+			t.Errorf("%q. body = %q, want %q", tt.name, got.Body.String(), want.Body.String())
+		}
+		if !tt.sameBody && got.Body.Len() == 0 {
+			t.Errorf("%q. empty body", tt.name)
+		}
+		if tt.pprof {
+			// the profiles differ from one capture to another, but must be alike for go tool pprof
+			wantProfile, err := profile.ParseData(want.Body.Bytes())
+			if err != nil {
+				t.Fatalf("%q. parse adaptor profile: %v", tt.name, err)
+			}
+			gotProfile, err := profile.ParseData(got.Body.Bytes())
+			if err != nil {
+				t.Errorf("%q. parse profile: %v", tt.name, err)
+				continue
+			}
+			if g, w := sampleTypes(gotProfile), sampleTypes(wantProfile); !reflect.DeepEqual(g, w) {
+				t.Errorf("%q. sample types = %v, want %v", tt.name, g, w)
+			}
+			if g, w := gotProfile.PeriodType, wantProfile.PeriodType; !reflect.DeepEqual(g, w) {
+				t.Errorf("%q. period type = %v, want %v", tt.name, g, w)
+			}
+		}
+	}
+}
+
+func sampleTypes(p *profile.Profile) []string {
+	types := make([]string, 0, len(p.SampleType))
+	for _, st := range p.SampleType {
+		types = append(types, st.Type+"/"+st.Unit)
+	}
+	return types
+}
+
+func Test_Native_Profile_Error(t *testing.T) {
+	assert.Nil(t, rpprof.StartCPUProfile(ioutil.Discard))
+	defer rpprof.StopCPUProfile()
+
+	adapted := server.New()
+	RegisterWithOptions(adapted, WithCoordinator(nil))
+	native := server.New()
+	RegisterWithOptions(native, WithCoordinator(nil), WithNativeHandlers())
+
+	want := ut.PerformRequest(adapted.Engine, http.MethodGet, "/debug/pprof/profile?seconds=1", nil)
+	got := ut.PerformRequest(native.Engine, http.MethodGet, "/debug/pprof/profile?seconds=1", nil)
+	assert.DeepEqual(t, http.StatusInternalServerError, got.Code)
+	assert.DeepEqual(t, want.Code, got.Code)
+	assert.DeepEqual(t, want.Body.String(), got.Body.String())
+	assert.DeepEqual(t, "1", got.Header().Get("X-Go-Pprof"))
+	assert.DeepEqual(t, "", got.Header().Get("Content-Disposition"))
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"github.com/hertz-contrib/pprof/auth"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// Option is the only way to configure the routes mounted by RegisterWithOptions,
+// RouteRegisterWithOptions, FgprofRegisterWithOptions and FgprofRouteRegisterWithOptions.
+// The same set of options can be shared by the pprof and fgprof registrations.
+type Option func(o *options)
+
+type options struct {
+	prefix       string
+	middlewares  []app.HandlerFunc
+	indexHandler app.HandlerFunc
+
+	expensiveMiddlewares []app.HandlerFunc
+
+	allowedCIDRs   []string
+	trustedProxies []string
+
+	coordinator *Coordinator
+
+	// maxDurations are keyed by the name of the capture: profile, trace or fgprof.
+	maxDurations  map[string]time.Duration
+	clientLimiter *rateLimiter
+	globalLimiter *rateLimiter
+
+	// enabled is the set of endpoints to mount, nil means all of them.
+	enabled  map[string]bool
+	disabled map[string]bool
+
+	native bool
+
+	store store.Store
+
+	// fgprofPaths are the paths fgprof is mounted on, listed by the discovery document.
+	fgprofPaths []string
+}
+
+func newOptions(defaultPrefix string, opts ...Option) *options {
+	o := &options{
+		prefix:      defaultPrefix,
+		coordinator: defaultCoordinator,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPrefix sets the url prefix the routes are mounted on.
+// If not set, DefaultPrefix is used for pprof and DefaultFgprofPrefix for fgprof.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithMiddleware appends middlewares to the chain in front of every route of the prefix group.
+func WithMiddleware(middlewares ...app.HandlerFunc) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithAllowedCIDRs only serves requests whose client IP is in one of cidrs, other
+// requests get 403. A plain IP is treated as a single host network. The client IP is
+// the remote address of the connection, see WithTrustedProxies for requests coming
+// through proxies. It panics on registration if a CIDR is malformed.
+func WithAllowedCIDRs(cidrs ...string) Option {
+	return func(o *options) {
+		o.allowedCIDRs = append(o.allowedCIDRs, cidrs...)
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For header is trusted by
+// WithAllowedCIDRs: if the connection comes from one of cidrs, the client IP is the
+// right-most X-Forwarded-For hop which is not a trusted proxy.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *options) {
+		o.trustedProxies = append(o.trustedProxies, cidrs...)
+	}
+}
+
+// WithAuth puts an auth.New middleware in front of every route of the prefix group,
+// a request is served if any of authenticators accepts it.
+func WithAuth(authenticators ...auth.Authenticator) Option {
+	return WithMiddleware(auth.New(authenticators...))
+}
+
+// WithExpensiveMiddleware appends middlewares to the chain in front of the endpoints
+// that keep sampling for the requested duration only: pprof /profile and /trace and
+// the fgprof root. They run after the ones set by WithMiddleware.
+func WithExpensiveMiddleware(middlewares ...app.HandlerFunc) Option {
+	return func(o *options) {
+		o.expensiveMiddlewares = append(o.expensiveMiddlewares, middlewares...)
+	}
+}
+
+// WithCoordinator sets the Coordinator serializing the CPU profile, trace and fgprof
+// captures, DefaultCoordinator is used if not set. Pass the same Coordinator to the
+// pprof and fgprof registrations to keep their captures apart, or nil to not
+// coordinate captures at all.
+func WithCoordinator(co *Coordinator) Option {
+	return func(o *options) {
+		o.coordinator = co
+	}
+}
+
+// WithMaxDuration rejects with 400 the captures called name asking for more than max
+// through the seconds query parameter, or by default. name is one of profile, trace
+// and fgprof. The seconds the handler of the capture cannot parse, e.g. 0.5 for profile
+// which expects an integer, are rejected with 400 too.
+func WithMaxDuration(name string, max time.Duration) Option {
+	switch name {
+	case "profile", "trace", "fgprof":
+	default:
+		panic(fmt.Sprintf("pprof: unknown capture %q", name))
+	}
+	return func(o *options) {
+		if o.maxDurations == nil {
+			o.maxDurations = make(map[string]time.Duration)
+		}
+		o.maxDurations[name] = max
+	}
+}
+
+// WithClientRateLimit limits the captures (CPU profile, trace and fgprof) of each
+// client IP with a token bucket: a token is added every interval, up to burst,
+// and requests exceeding it get 429. The client IP is resolved as for WithAllowedCIDRs.
+// The bucket is created by this call, so the returned Option can be passed to several
+// registrations to share the limit.
+func WithClientRateLimit(every time.Duration, burst int) Option {
+	limiter := newRateLimiter(every, burst)
+	return func(o *options) {
+		o.clientLimiter = limiter
+	}
+}
+
+// WithGlobalRateLimit is like WithClientRateLimit, but with one bucket for all clients.
+func WithGlobalRateLimit(every time.Duration, burst int) Option {
+	limiter := newRateLimiter(every, burst)
+	return func(o *options) {
+		o.globalLimiter = limiter
+	}
+}
+
+// WithIndexHandler replaces the default index page served at the prefix root.
+// It has no effect on fgprof, whose prefix root is the profile itself.
+func WithIndexHandler(h app.HandlerFunc) Option {
+	return func(o *options) {
+		o.indexHandler = h
+	}
+}
+
+// WithEndpoints mounts only the named endpoints, e.g. "heap", "goroutine", "profile".
+// The names are the paths under the prefix: cmdline, profile, symbol, trace, allocs,
+// block, goroutine, heap, mutex and threadcreate. The index page is always mounted and
+// only lists the endpoints that were actually registered.
+func WithEndpoints(names ...string) Option {
+	return func(o *options) {
+		if o.enabled == nil {
+			o.enabled = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			o.enabled[name] = true
+		}
+	}
+}
+
+// WithoutEndpoints skips mounting the named endpoints, e.g. "cmdline" and "trace".
+// It takes precedence over WithEndpoints.
+func WithoutEndpoints(names ...string) Option {
+	return func(o *options) {
+		if o.disabled == nil {
+			o.disabled = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			o.disabled[name] = true
+		}
+	}
+}
+
+// WithNativeHandlers serves the endpoints with handlers written for hertz rather than
+// the net/http/pprof ones through the adaptor, replying the same bytes without the
+// conversion overhead. The profiles are then held in memory until complete, rather
+// than streamed, and a capture is not stopped when the client goes away.
+// It has no effect on fgprof.
+func WithNativeHandlers() Option {
+	return func(o *options) {
+		o.native = true
+	}
+}
+
+// WithStore mounts the profiles kept by s, e.g. the ones of RequestProfileMiddleware or of
+// the continuous package: "profiles" lists them in JSON, newest first, optionally filtered
+// by the type and source query parameters, "profiles/:id" downloads one of them, and
+// "diff" compares two of them.
+// The allocs, heap, block and mutex profiles are then served as delta profiles from
+// a stored baseline too, e.g. taken by a continuous.Profiler, with the delta=since:<id> or
+// window=<duration> query parameters.
+// It has no effect on fgprof.
+func WithStore(s store.Store) Option {
+	return func(o *options) {
+		o.store = s
+	}
+}
+
+// WithFgprof lists fgprof mounted on path, e.g. DefaultFgprofPrefix, in the discovery
+// document of the index, as the fgprof routes are registered apart from the pprof ones.
+// It may be used several times, and has no effect on fgprof.
+func WithFgprof(path string) Option {
+	return func(o *options) {
+		o.fgprofPaths = append(o.fgprofPaths, path)
+	}
+}
+
+// groupMiddlewares returns the middlewares of the prefix group, the IP allowlist
+// goes first so that rejected requests do not reach any other middleware.
+func (o *options) groupMiddlewares() []app.HandlerFunc {
+	if len(o.allowedCIDRs) == 0 {
+		return o.middlewares
+	}
+	handlers := make([]app.HandlerFunc, 0, len(o.middlewares)+1)
+	handlers = append(handlers, newIPAllowlist(o.allowedCIDRs, o.trustedProxies).middleware())
+	return append(handlers, o.middlewares...)
+}
+
+// expensiveHandlers returns the handler chain of the expensive endpoint called name,
+// which samples for defaultSeconds unless asked otherwise.
+func (o *options) expensiveHandlers(name string, defaultSeconds int, h app.HandlerFunc) []app.HandlerFunc {
+	handlers := make([]app.HandlerFunc, 0, len(o.expensiveMiddlewares)+4)
+	handlers = append(handlers, o.expensiveMiddlewares...)
+	if max, ok := o.maxDurations[name]; ok {
+		handlers = append(handlers, maxDurationMiddleware(name, max, defaultSeconds))
+	}
+	if o.clientLimiter != nil || o.globalLimiter != nil {
+		handlers = append(handlers, rateLimitMiddleware(o.clientLimiter, o.globalLimiter, o.trustedProxies))
+	}
+	if o.coordinator != nil {
+		handlers = append(handlers, o.coordinator.middleware(name, defaultSeconds))
+	}
+	return append(handlers, h)
+}
+
+func (o *options) endpointEnabled(name string) bool {
+	if o.disabled[name] {
+		return false
+	}
+	return o.enabled == nil || o.enabled[name]
+}
+
+// checkEndpoints panics if an endpoint name given by option is not one of known.
+func (o *options) checkEndpoints(known []endpoint) {
+	names := make(map[string]bool, len(known))
+	for _, e := range known {
+		names[e.name] = true
+	}
+	for _, set := range []map[string]bool{o.enabled, o.disabled} {
+		for name := range set {
+			if !names[name] {
+				panic(fmt.Sprintf("pprof: unknown endpoint %q", name))
+			}
+		}
+	}
+}
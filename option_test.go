@@ -0,0 +1,64 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+)
+
+func Test_newOptions(t *testing.T) {
+	o := newOptions(DefaultPrefix)
+	assert.DeepEqual(t, DefaultPrefix, o.prefix)
+	assert.DeepEqual(t, 0, len(o.middlewares))
+	assert.Nil(t, o.indexHandler)
+
+	mw := func(c context.Context, ctx *app.RequestContext) {}
+	o = newOptions(DefaultFgprofPrefix,
+		WithPrefix("/admin/pprof"),
+		WithMiddleware(mw),
+		WithMiddleware(mw, mw),
+		WithIndexHandler(mw),
+	)
+	assert.DeepEqual(t, "/admin/pprof", o.prefix)
+	assert.DeepEqual(t, 3, len(o.middlewares))
+	assert.NotNil(t, o.indexHandler)
+}
+
+func Test_options_endpointEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]bool
+	}{
+		{"default value", nil, map[string]bool{"heap": true, "cmdline": true}},
+		{"enabled", []Option{WithEndpoints("heap")}, map[string]bool{"heap": true, "cmdline": false}},
+		{"disabled", []Option{WithoutEndpoints("cmdline")}, map[string]bool{"heap": true, "cmdline": false}},
+		{"both", []Option{WithEndpoints("heap", "cmdline"), WithoutEndpoints("cmdline")}, map[string]bool{"heap": true, "cmdline": false}},
+	}
+	for _, tt := range tests {
+		o := newOptions(DefaultPrefix, tt.opts...)
+		for name, want := range tt.want {
+			if got := o.endpointEnabled(name); got != want {
+				t.Errorf("%q. endpointEnabled(%q) = %v, want %v", tt.name, name, got, want)
+			}
+		}
+	}
+}
@@ -42,9 +42,12 @@
 package pprof
 
 import (
+	"net/http"
 	"net/http/pprof"
 
+	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"github.com/cloudwego/hertz/pkg/route"
 	"github.com/hertz-contrib/pprof/adaptor"
 )
@@ -54,6 +57,52 @@ const (
 	DefaultPrefix = "/debug/pprof"
 )
 
+// endpoint is a route mounted under the pprof prefix, named after its path.
+type endpoint struct {
+	name    string
+	methods []string
+	handler app.HandlerFunc
+	// expensive endpoints keep sampling for a while, defaultSeconds unless
+	// asked otherwise, see WithExpensiveMiddleware.
+	expensive      bool
+	defaultSeconds int
+}
+
+// pprofEndpoints returns every endpoint RouteRegisterWithOptions is able to mount,
+// served by the net/http/pprof handlers, or by their hertz version if native.
+func pprofEndpoints(native bool) []endpoint {
+	cmdline, profile, symbol, trace := streamHandler(pprof.Cmdline), streamHandler(pprof.Profile),
+		streamHandler(pprof.Symbol), streamHandler(pprof.Trace)
+	named := func(name string) app.HandlerFunc {
+		return streamHandler(pprof.Handler(name).ServeHTTP)
+	}
+	if native {
+		cmdline, profile, symbol, trace = nativeCmdline, nativeProfile, nativeSymbol, nativeTrace
+		named = nativeHandler
+	}
+
+	get := []string{consts.MethodGet}
+	return []endpoint{
+		{"cmdline", get, cmdline, false, 0},
+		{"profile", get, profile, true, 30},
+		{"symbol", []string{consts.MethodPost, consts.MethodGet}, symbol, false, 0},
+		{"trace", get, trace, true, 1},
+		{"allocs", get, named("allocs"), false, 0},
+		{"block", get, named("block"), false, 0},
+		{"goroutine", get, named("goroutine"), false, 0},
+		{"heap", get, named("heap"), false, 0},
+		{"mutex", get, named("mutex"), false, 0},
+		{"threadcreate", get, named("threadcreate"), false, 0},
+	}
+}
+
+// streamHandler wraps a net/http/pprof handler, streaming the profile to the
+// client as it is written rather than holding it in memory, and stopping the
+// capture if the client goes away.
+func streamHandler(h http.HandlerFunc) app.HandlerFunc {
+	return adaptor.NewHertzHTTPHandlerFunc(h, adaptor.WithStreaming(), adaptor.WithCancelOnDisconnect())
+}
+
 func getPrefix(prefixOptions ...string) string {
 	prefix := DefaultPrefix
 	if len(prefixOptions) > 0 {
@@ -73,22 +122,59 @@ func Register(r *server.Hertz, prefixOptions ...string) {
 // the provided hertz.RouterGroup. prefixOptions is a optional. If not prefixOptions,
 // the default path prefix is used, otherwise first prefixOptions will be path prefix.
 func RouteRegister(rg *route.RouterGroup, prefixOptions ...string) {
-	prefix := getPrefix(prefixOptions...)
+	RouteRegisterWithOptions(rg, WithPrefix(getPrefix(prefixOptions...)))
+}
+
+// RegisterWithOptions registers the standard HandlerFuncs from the net/http/pprof package
+// with the provided hertz.Hertz, configured by opts.
+func RegisterWithOptions(r *server.Hertz, opts ...Option) {
+	RouteRegisterWithOptions(&(r.RouterGroup), opts...)
+}
+
+// RouteRegisterWithOptions registers the standard HandlerFuncs from the net/http/pprof package
+// with the provided hertz.RouterGroup, configured by opts.
+func RouteRegisterWithOptions(rg *route.RouterGroup, opts ...Option) {
+	o := newOptions(DefaultPrefix, opts...)
+
+	all := pprofEndpoints(o.native)
+	if o.store != nil {
+		all = append(all,
+			endpoint{"profiles", []string{consts.MethodGet}, listProfilesHandler(o.store), false, 0},
+			endpoint{"diff", []string{consts.MethodGet}, diffHandler(o.store), false, 0},
+		)
+	}
+	o.checkEndpoints(all)
+
+	var mounted []endpoint
+	for _, e := range all {
+		if o.endpointEnabled(e.name) {
+			mounted = append(mounted, e)
+		}
+	}
+
+	index := o.indexHandler
+	if index == nil {
+		index = newIndexHandler(mounted, o.store != nil, o.fgprofPaths)
+	}
 
-	prefixRouter := rg.Group(prefix)
+	prefixRouter := rg.Group(o.prefix, o.groupMiddlewares()...)
 	{
-		prefixRouter.GET("/", adaptor.NewHertzHTTPHandlerFunc(pprof.Index))
-		prefixRouter.GET("/cmdline", adaptor.NewHertzHTTPHandlerFunc(pprof.Cmdline))
-
-		prefixRouter.GET("/profile", adaptor.NewHertzHTTPHandlerFunc(pprof.Profile))
-		prefixRouter.POST("/symbol", adaptor.NewHertzHTTPHandlerFunc(pprof.Symbol))
-		prefixRouter.GET("/symbol", adaptor.NewHertzHTTPHandlerFunc(pprof.Symbol))
-		prefixRouter.GET("/trace", adaptor.NewHertzHTTPHandlerFunc(pprof.Trace))
-		prefixRouter.GET("/allocs", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("allocs").ServeHTTP))
-		prefixRouter.GET("/block", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("block").ServeHTTP))
-		prefixRouter.GET("/goroutine", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("goroutine").ServeHTTP))
-		prefixRouter.GET("/heap", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("heap").ServeHTTP))
-		prefixRouter.GET("/mutex", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("mutex").ServeHTTP))
-		prefixRouter.GET("/threadcreate", adaptor.NewHertzHTTPHandlerFunc(pprof.Handler("threadcreate").ServeHTTP))
+		prefixRouter.GET("/", index)
+		for _, e := range mounted {
+			handler := e.handler
+			if _, ok := deltaBaselines[e.name]; ok && o.store != nil {
+				handler = deltaHandler(o.store, e.name, handler)
+			}
+			handlers := []app.HandlerFunc{handler}
+			if e.expensive {
+				handlers = o.expensiveHandlers(e.name, e.defaultSeconds, e.handler)
+			}
+			for _, method := range e.methods {
+				prefixRouter.Handle(method, "/"+e.name, handlers...)
+			}
+			if e.name == "profiles" {
+				prefixRouter.GET("/profiles/:id", getProfileHandler(o.store))
+			}
+		}
 	}
 }
@@ -47,11 +47,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/cloudwego/hertz/pkg/common/test/assert"
 	"github.com/cloudwego/hertz/pkg/common/ut"
+
+	"github.com/hertz-contrib/pprof/auth"
 )
 
 func Test_getPrefix(t *testing.T) {
@@ -168,3 +171,149 @@ func Test_Pprof_Other(t *testing.T) {
 	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/302", nil)
 	assert.DeepEqual(t, http.StatusNotFound, resp.Code)
 }
+
+func Test_Pprof_With_Options(t *testing.T) {
+	bearerToken := "Bearer token"
+	h := server.New()
+	RegisterWithOptions(h,
+		WithPrefix("/admin/pprof"),
+		WithMiddleware(func(c context.Context, ctx *app.RequestContext) {
+			if ctx.Request.Header.Get("Authorization") != bearerToken {
+				ctx.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			ctx.Next(c)
+		}),
+		WithIndexHandler(func(c context.Context, ctx *app.RequestContext) {
+			ctx.String(http.StatusOK, "custom index")
+		}),
+	)
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/admin/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+
+	header := ut.Header{
+		Key:   "Authorization",
+		Value: bearerToken,
+	}
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/admin/pprof/heap", nil, header)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/admin/pprof/", nil, header)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.DeepEqual(t, nil, err)
+	assert.DeepEqual(t, "custom index", string(b))
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/", nil)
+	assert.DeepEqual(t, http.StatusNotFound, resp.Code)
+}
+
+func Test_Pprof_Endpoints(t *testing.T) {
+	h := server.New()
+	RegisterWithOptions(h, WithoutEndpoints("cmdline", "trace"))
+	RegisterWithOptions(h, WithPrefix("/heap/pprof"), WithEndpoints("heap"))
+
+	for _, sub := range []string{"cmdline", "trace"} {
+		resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/"+sub, nil)
+		assert.DeepEqual(t, http.StatusNotFound, resp.Code)
+	}
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.DeepEqual(t, nil, err)
+	assert.DeepEqual(t, true, bytes.Contains(b, []byte("<title>/debug/pprof/</title>")))
+	assert.DeepEqual(t, true, bytes.Contains(b, []byte("href='heap?debug=1'")))
+	assert.DeepEqual(t, false, bytes.Contains(b, []byte("href='cmdline?debug=1'")))
+	assert.DeepEqual(t, false, bytes.Contains(b, []byte("href='trace?debug=1'")))
+
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/heap/pprof/goroutine", nil)
+	assert.DeepEqual(t, http.StatusNotFound, resp.Code)
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/heap/pprof/", nil)
+	b, err = ioutil.ReadAll(resp.Body)
+	assert.DeepEqual(t, nil, err)
+	assert.DeepEqual(t, true, bytes.Contains(b, []byte("href='heap?debug=1'")))
+	assert.DeepEqual(t, false, bytes.Contains(b, []byte("full goroutine stack dump")))
+
+	assert.Panic(t, func() {
+		RegisterWithOptions(server.New(), WithEndpoints("unknown"))
+	})
+}
+
+func Test_Pprof_Expensive_Middleware(t *testing.T) {
+	h := server.New()
+	RegisterWithOptions(h, WithExpensiveMiddleware(func(c context.Context, ctx *app.RequestContext) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}))
+
+	for _, sub := range []string{"profile", "trace"} {
+		resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/"+sub, nil)
+		assert.DeepEqual(t, http.StatusForbidden, resp.Code)
+	}
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+}
+
+func Test_Pprof_With_Auth(t *testing.T) {
+	h := server.New()
+	RegisterWithOptions(h, WithAuth(auth.Bearer(func(ctx context.Context, token string) bool {
+		return token == "token"
+	})))
+
+	resp := ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil)
+	assert.DeepEqual(t, http.StatusUnauthorized, resp.Code)
+
+	header := ut.Header{
+		Key:   "Authorization",
+		Value: "Bearer token",
+	}
+	resp = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/heap", nil, header)
+	assert.DeepEqual(t, http.StatusOK, resp.Code)
+}
+
+func Test_Pprof_Streaming(t *testing.T) {
+	h := server.New(server.WithHostPorts("127.0.0.1:10044"))
+	Register(h)
+	go h.Run()
+	defer h.Close()
+	time.Sleep(time.Millisecond * 500)
+
+	resp, err := http.Get("http://127.0.0.1:10044/debug/pprof/trace?seconds=0.5")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.DeepEqual(t, http.StatusOK, resp.StatusCode)
+	assert.DeepEqual(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.DeepEqual(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, true, len(b) > 0)
+}
+
+func Test_Pprof_Client_Disconnect(t *testing.T) {
+	h := server.New(server.WithHostPorts("127.0.0.1:10045"))
+	coordinator := NewCoordinator(0, 0)
+	RegisterWithOptions(h, WithCoordinator(coordinator))
+	go h.Run()
+	defer h.Close()
+	time.Sleep(time.Millisecond * 500)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:10045/debug/pprof/profile?seconds=30", nil)
+	assert.Nil(t, err)
+	go func() {
+		time.Sleep(time.Millisecond * 500)
+		assert.NotNil(t, coordinator.Holder())
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	assert.NotNil(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for coordinator.Holder() != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 50)
+	}
+	assert.Assertf(t, coordinator.Holder() == nil, "profile kept running after the client disconnected")
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"github.com/hertz-contrib/pprof/store"
+)
+
+// storedProfile is the JSON form of a store.Profile listed by the profiles endpoint.
+type storedProfile struct {
+	ID              string            `json:"id"`
+	Type            string            `json:"type"`
+	Source          string            `json:"source"`
+	StartedAt       string            `json:"started_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Size            int64             `json:"size"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// listProfilesHandler lists the profiles kept by s, filtered by the type and source
+// query parameters if given.
+func listProfilesHandler(s store.Store) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		profiles, err := s.List()
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+
+		typ, source := c.Query("type"), c.Query("source")
+		list := make([]storedProfile, 0, len(profiles))
+		for _, p := range profiles {
+			if (typ != "" && p.Type != typ) || (source != "" && p.Source != source) {
+				continue
+			}
+			list = append(list, storedProfile{
+				ID:              p.ID,
+				Type:            p.Type,
+				Source:          p.Source,
+				StartedAt:       p.StartedAt.UTC().Format(time.RFC3339),
+				DurationSeconds: p.Duration.Seconds(),
+				Size:            p.Size,
+				Labels:          p.Labels,
+			})
+		}
+		c.JSON(consts.StatusOK, list)
+	}
+}
+
+// getProfileHandler downloads the profile of s named by the id route parameter.
+func getProfileHandler(s store.Store) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		p, data, err := s.Get(c.Param("id"))
+		if errors.Is(err, store.ErrNotFound) {
+			c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.pprof"`, p.Type, p.ID))
+		c.Data(consts.StatusOK, "application/octet-stream", data)
+	}
+}
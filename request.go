@@ -0,0 +1,208 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/felixge/fgprof"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/auth"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+const (
+	// ProfileHeader is the request header asking for the request to be profiled. Its value
+	// is "cpu" or "fgprof" signed with auth.Signer.SignValue, so that only the holders of
+	// the secret can have requests profiled.
+	ProfileHeader = "X-Hertz-Profile"
+	// ProfileIDHeader is the response header carrying the ID of the profile of the request.
+	ProfileIDHeader = "X-Hertz-Profile-Id"
+	// ProfileErrorHeader is the response header telling why the request was not profiled.
+	ProfileErrorHeader = "X-Hertz-Profile-Error"
+	// ProfileIDLabel is the pprof label carrying the profile ID of a profiled request.
+	ProfileIDLabel = "profile_id"
+
+	requestProfileSource = "request"
+)
+
+// RequestProfileOption configures the middleware returned by RequestProfileMiddleware.
+type RequestProfileOption func(o *requestProfileOptions)
+
+type requestProfileOptions struct {
+	coordinator *Coordinator
+	maxDuration time.Duration
+}
+
+// WithRequestProfileCoordinator sets the Coordinator the profiles of requests take turns
+// with the other captures on, DefaultCoordinator() by default, or nil to not coordinate
+// them at all.
+func WithRequestProfileCoordinator(co *Coordinator) RequestProfileOption {
+	return func(o *requestProfileOptions) {
+		o.coordinator = co
+	}
+}
+
+// WithRequestProfileMaxDuration bounds the profile of a request, 30 seconds by default.
+// The request goes on unprofiled once it is over.
+func WithRequestProfileMaxDuration(d time.Duration) RequestProfileOption {
+	return func(o *requestProfileOptions) {
+		o.maxDuration = d
+	}
+}
+
+// RequestProfileMiddleware returns a middleware profiling the requests carrying a valid
+// ProfileHeader for their lifetime, and putting the profile in s, e.g. to profile a single
+// slow request in production:
+//
+//	curl -H "X-Hertz-Profile: $(signed cpu)" -D - https://example.com/slow
+//
+// The ID of the profile is returned in ProfileIDHeader, it can be downloaded from the
+// routes registered WithStore(s). If the request could not be profiled, e.g. because
+// another capture is running, the reason is returned in ProfileErrorHeader instead.
+//
+// The CPU profile only keeps the samples of the goroutines of the request, labelled with
+// ProfileIDLabel, the fgprof one has the wall-clock time of every goroutine. Requests
+// with an invalid header are served as if there was none.
+func RequestProfileMiddleware(s store.Store, signer *auth.Signer, opts ...RequestProfileOption) app.HandlerFunc {
+	o := &requestProfileOptions{
+		coordinator: defaultCoordinator,
+		maxDuration: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		header := c.GetHeader(ProfileHeader)
+		if len(header) == 0 {
+			c.Next(ctx)
+			return
+		}
+		kind, ok := signer.VerifyValue(string(header))
+		if !ok || (kind != "cpu" && kind != "fgprof") {
+			c.Next(ctx)
+			return
+		}
+
+		release := func() {}
+		if o.coordinator != nil {
+			var err error
+			if release, err = o.coordinator.tryAcquire(kind+" "+c.FullPath(), o.maxDuration); err != nil {
+				c.Header(ProfileErrorHeader, err.Error())
+				c.Next(ctx)
+				return
+			}
+		}
+
+		p := store.Profile{
+			ID:     store.NewID(),
+			Type:   kind,
+			Source: requestProfileSource,
+			Labels: map[string]string{
+				RouteLabel:  c.FullPath(),
+				MethodLabel: string(c.Method()),
+			},
+		}
+		var buf bytes.Buffer
+		stopProfile, err := startRequestProfile(kind, &buf)
+		if err != nil {
+			release()
+			c.Header(ProfileErrorHeader, err.Error())
+			c.Next(ctx)
+			return
+		}
+		// the other captures may run as soon as the profile is over,
+		// even though the request goes on past maxDuration.
+		var once sync.Once
+		stop := func() {
+			once.Do(func() {
+				stopProfile()
+				release()
+			})
+		}
+		p.StartedAt = time.Now()
+		timer := time.AfterFunc(o.maxDuration, stop)
+		defer timer.Stop()
+
+		c.Header(ProfileIDHeader, p.ID)
+		pprof.Do(ctx, pprof.Labels(ProfileIDLabel, p.ID), func(ctx context.Context) {
+			c.Next(ctx)
+		})
+		stop()
+		p.Duration = time.Since(p.StartedAt)
+		if p.Duration > o.maxDuration {
+			p.Duration = o.maxDuration
+		}
+
+		data := buf.Bytes()
+		if kind == "cpu" {
+			if data, err = filterProfile(data, ProfileIDLabel, p.ID); err != nil {
+				hlog.CtxErrorf(ctx, "HERTZ: filter profile id=%s error: %v", p.ID, err)
+				return
+			}
+		}
+		if _, err := s.Put(p, data); err != nil {
+			hlog.CtxErrorf(ctx, "HERTZ: store profile id=%s error: %v", p.ID, err)
+		}
+	}
+}
+
+// startRequestProfile starts the kind of profile writing to w, until stop is called.
+func startRequestProfile(kind string, w *bytes.Buffer) (stop func(), err error) {
+	var once sync.Once
+	switch kind {
+	case "cpu":
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return nil, err
+		}
+		return func() { once.Do(pprof.StopCPUProfile) }, nil
+	case "fgprof":
+		stopFgprof := fgprof.Start(w, fgprof.FormatPprof)
+		return func() { once.Do(func() { stopFgprof() }) }, nil // nolint:errcheck
+	}
+	return nil, errors.New("pprof: unknown profile " + kind)
+}
+
+// filterProfile only keeps the samples of the profile whose label key has the given value.
+func filterProfile(data []byte, key, value string) ([]byte, error) {
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, err
+	}
+	p.FilterSamplesByTag(func(s *profile.Sample) bool {
+		for _, v := range s.Label[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}, nil)
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
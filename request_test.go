@@ -0,0 +1,195 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pprof
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/google/pprof/profile"
+
+	"github.com/hertz-contrib/pprof/auth"
+	"github.com/hertz-contrib/pprof/store"
+)
+
+func spin(d time.Duration) {
+	for deadline := time.Now().Add(d); time.Now().Before(deadline); {
+	}
+}
+
+func Test_Request_Profile_Middleware(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	signer := auth.NewSigner([]byte("secret"))
+
+	h := server.New()
+	h.Use(RequestProfileMiddleware(s, signer, WithRequestProfileCoordinator(NewCoordinator(0, 0))))
+	h.GET("/slow/:id", func(ctx context.Context, c *app.RequestContext) {
+		// work in another goroutine of the request, which inherits its labels
+		done := make(chan struct{})
+		go func() {
+			spin(200 * time.Millisecond)
+			close(done)
+		}()
+		spin(200 * time.Millisecond)
+		<-done
+		c.String(consts.StatusOK, "ok")
+	})
+	RegisterWithOptions(h, WithStore(s))
+
+	// requests without a valid header are not profiled
+	for _, value := range []string{"", "cpu", signer.SignValue("heap", time.Minute), signer.SignValue("cpu", -time.Minute)} {
+		var headers []ut.Header
+		if value != "" {
+			headers = append(headers, ut.Header{Key: ProfileHeader, Value: value})
+		}
+		w := ut.PerformRequest(h.Engine, http.MethodGet, "/slow/1", nil, headers...)
+		assert.DeepEqual(t, consts.StatusOK, w.Code)
+		assert.DeepEqual(t, "", w.Header().Get(ProfileIDHeader))
+	}
+	profiles, _ := s.List()
+	assert.DeepEqual(t, 0, len(profiles))
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/slow/1", nil,
+		ut.Header{Key: ProfileHeader, Value: signer.SignValue("cpu", time.Minute)})
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	id := w.Header().Get(ProfileIDHeader)
+	assert.True(t, id != "")
+
+	p, data, err := s.Get(id)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "cpu", p.Type)
+	assert.DeepEqual(t, "request", p.Source)
+	assert.DeepEqual(t, map[string]string{"http.route": "/slow/:id", "http.method": "GET"}, p.Labels)
+	assert.True(t, p.Duration >= 200*time.Millisecond)
+
+	prof, err := profile.ParseData(data)
+	assert.Nil(t, err)
+	assert.True(t, len(prof.Sample) > 0)
+	for _, sample := range prof.Sample {
+		assert.DeepEqual(t, []string{id}, sample.Label[ProfileIDLabel])
+	}
+
+	// the profile is listed and downloaded from the store routes
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/profiles?source=request", nil)
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	var list []storedProfile
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.DeepEqual(t, 1, len(list))
+	assert.DeepEqual(t, id, list[0].ID)
+	assert.DeepEqual(t, int64(len(data)), list[0].Size)
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/profiles?type=fgprof", nil)
+	assert.DeepEqual(t, "[]", w.Body.String())
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/profiles/"+id, nil)
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	assert.DeepEqual(t, data, w.Body.Bytes())
+	assert.DeepEqual(t, `attachment; filename="cpu-`+id+`.pprof"`, w.Header().Get("Content-Disposition"))
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/debug/pprof/profiles/unknown", nil)
+	assert.DeepEqual(t, consts.StatusNotFound, w.Code)
+}
+
+func Test_Request_Profile_Busy(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	signer := auth.NewSigner([]byte("secret"))
+	co := NewCoordinator(0, 0)
+
+	h := server.New()
+	h.Use(RequestProfileMiddleware(s, signer, WithRequestProfileCoordinator(co)))
+	h.GET("/ping", func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "pong")
+	})
+
+	release, err := co.Acquire(context.Background(), "profile", time.Minute)
+	assert.Nil(t, err)
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/ping", nil,
+		ut.Header{Key: ProfileHeader, Value: signer.SignValue("fgprof", time.Minute)})
+	release()
+
+	// the request is served unprofiled
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	assert.DeepEqual(t, "pong", w.Body.String())
+	assert.DeepEqual(t, "", w.Header().Get(ProfileIDHeader))
+	assert.True(t, w.Header().Get(ProfileErrorHeader) != "")
+
+	w = ut.PerformRequest(h.Engine, http.MethodGet, "/ping", nil,
+		ut.Header{Key: ProfileHeader, Value: signer.SignValue("fgprof", time.Minute)})
+	id := w.Header().Get(ProfileIDHeader)
+	p, _, err := s.Get(id)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "fgprof", p.Type)
+}
+
+func Test_Request_Profile_Max_Duration(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	signer := auth.NewSigner([]byte("secret"))
+	co := NewCoordinator(0, 0)
+
+	h := server.New()
+	h.Use(RequestProfileMiddleware(s, signer,
+		WithRequestProfileCoordinator(co),
+		WithRequestProfileMaxDuration(100*time.Millisecond),
+	))
+	acquired := make(chan error, 1)
+	h.GET("/slow", func(ctx context.Context, c *app.RequestContext) {
+		time.Sleep(300 * time.Millisecond)
+		// the profile is over, other captures can run while the request goes on
+		release, err := co.Acquire(ctx, "profile", time.Minute)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+		c.String(consts.StatusOK, "ok")
+	})
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/slow", nil,
+		ut.Header{Key: ProfileHeader, Value: signer.SignValue("cpu", time.Minute)})
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	assert.Nil(t, <-acquired)
+
+	p, _, err := s.Get(w.Header().Get(ProfileIDHeader))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 100*time.Millisecond, p.Duration)
+	assert.True(t, co.Holder() == nil)
+}
+
+func Test_Request_Profile_Without_Coordinator(t *testing.T) {
+	s := store.NewMemoryStore(10)
+	signer := auth.NewSigner([]byte("secret"))
+
+	h := server.New()
+	h.Use(RequestProfileMiddleware(s, signer, WithRequestProfileCoordinator(nil)))
+	h.GET("/ping", func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "pong")
+	})
+
+	w := ut.PerformRequest(h.Engine, http.MethodGet, "/ping", nil,
+		ut.Header{Key: ProfileHeader, Value: signer.SignValue("fgprof", time.Minute)})
+	assert.DeepEqual(t, consts.StatusOK, w.Code)
+	p, _, err := s.Get(w.Header().Get(ProfileIDHeader))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "fgprof", p.Type)
+}
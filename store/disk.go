@@ -0,0 +1,238 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dataExt = ".pprof"
+	metaExt = ".json"
+)
+
+// DiskOption configures a DiskStore.
+type DiskOption func(s *DiskStore)
+
+// WithMaxAge drops the profiles started more than d ago, 0 means to keep them regardless of age.
+func WithMaxAge(d time.Duration) DiskOption {
+	return func(s *DiskStore) {
+		s.maxAge = d
+	}
+}
+
+// WithMaxSize drops the oldest profiles once they take more than size bytes, 0 means no limit.
+// The newest profile is always kept.
+func WithMaxSize(size int64) DiskOption {
+	return func(s *DiskStore) {
+		s.maxSize = size
+	}
+}
+
+// DiskStore is a Store keeping the profiles in a local directory, as an "<id>.pprof" file
+// along with an "<id>.json" file describing it, so that they outlive the process.
+type DiskStore struct {
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+
+	mu sync.Mutex
+	// profiles are ordered from the oldest to the newest.
+	profiles []Profile
+}
+
+// NewDiskStore returns a DiskStore keeping the profiles in dir, which is created if needed.
+// The profiles already in dir are kept, subject to the retention options.
+func NewDiskStore(dir string, opts ...DiskOption) (*DiskStore, error) {
+	s := &DiskStore{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+metaExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var p Profile
+		if err := json.Unmarshal(b, &p); err != nil || p.ID+metaExt != filepath.Base(file) {
+			// not one of ours
+			continue
+		}
+		s.profiles = append(s.profiles, p)
+	}
+	sort.Slice(s.profiles, func(i, j int) bool {
+		return s.profiles[i].ID < s.profiles[j].ID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	return s, nil
+}
+
+// Put implements Store.
+func (s *DiskStore) Put(p Profile, data []byte) (Profile, error) {
+	if p.ID == "" {
+		p.ID = NewID()
+	}
+	if !validID(p.ID) {
+		return Profile{}, errors.New("store: invalid profile id " + p.ID)
+	}
+	p.Size = int64(len(data))
+	meta, err := json.Marshal(p)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the description goes last, a profile is only loaded back once it is complete.
+	if err := writeFile(s.path(p.ID, dataExt), data); err != nil {
+		return Profile{}, err
+	}
+	if err := writeFile(s.path(p.ID, metaExt), meta); err != nil {
+		os.Remove(s.path(p.ID, dataExt)) // nolint:errcheck
+		return Profile{}, err
+	}
+	s.profiles = append(s.profiles, p)
+	s.prune()
+	return p, nil
+}
+
+// Get implements Store.
+func (s *DiskStore) Get(id string) (Profile, []byte, error) {
+	s.mu.Lock()
+	s.prune()
+	p, ok := s.lookup(id)
+	s.mu.Unlock()
+	if !ok {
+		return Profile{}, nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id, dataExt))
+	if os.IsNotExist(err) {
+		// dropped in the meantime
+		return Profile{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return Profile{}, nil, err
+	}
+	return p, data, nil
+}
+
+// List implements Store.
+func (s *DiskStore) List() ([]Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	profiles := make([]Profile, 0, len(s.profiles))
+	for i := len(s.profiles) - 1; i >= 0; i-- {
+		profiles = append(profiles, s.profiles[i])
+	}
+	return profiles, nil
+}
+
+func (s *DiskStore) lookup(id string) (Profile, bool) {
+	for _, p := range s.profiles {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// prune drops the profiles out of the retention limits, s.mu must be held.
+func (s *DiskStore) prune() {
+	var deadline time.Time
+	if s.maxAge > 0 {
+		deadline = time.Now().Add(-s.maxAge)
+	}
+	// the newest profiles are kept first, within the size limit.
+	keep := make([]bool, len(s.profiles))
+	size := int64(0)
+	for i := len(s.profiles) - 1; i >= 0; i-- {
+		p := s.profiles[i]
+		if p.StartedAt.Before(deadline) {
+			continue
+		}
+		size += p.Size
+		if s.maxSize > 0 && size > s.maxSize && i < len(s.profiles)-1 {
+			break
+		}
+		keep[i] = true
+	}
+
+	profiles := s.profiles[:0]
+	for i, p := range s.profiles {
+		if keep[i] {
+			profiles = append(profiles, p)
+			continue
+		}
+		// the description goes first, not to load back a profile without its data.
+		os.Remove(s.path(p.ID, metaExt)) // nolint:errcheck
+		os.Remove(s.path(p.ID, dataExt)) // nolint:errcheck
+	}
+	for i := len(profiles); i < len(s.profiles); i++ {
+		s.profiles[i] = Profile{}
+	}
+	s.profiles = profiles
+}
+
+func (s *DiskStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// validID reports whether id is safe to use as a file name.
+func validID(id string) bool {
+	return id != "." && id != ".." && !strings.ContainsAny(id, `/\`+"\x00")
+}
+
+// writeFile writes data to a temporary file renamed to name, so that name is either
+// missing or complete.
+func writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		f.Close() // nolint:errcheck
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name()) // nolint:errcheck
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	s, err := NewDiskStore(dir, WithMaxSize(10))
+	assert.Nil(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first, err := s.Put(Profile{Type: "cpu", StartedAt: now, Duration: time.Second, Labels: map[string]string{"k": "v"}}, []byte("first"))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, int64(5), first.Size)
+	// IDs sort in the order of creation from one millisecond to the next
+	time.Sleep(2 * time.Millisecond)
+	second, err := s.Put(Profile{Type: "heap", StartedAt: now}, []byte("second"))
+	assert.Nil(t, err)
+
+	// the first profile is dropped once over the size limit
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []Profile{second}, list)
+	_, _, err = s.Get(first.ID)
+	assert.DeepEqual(t, ErrNotFound, err)
+	_, err = os.Stat(filepath.Join(dir, first.ID+".pprof"))
+	assert.True(t, os.IsNotExist(err))
+
+	time.Sleep(2 * time.Millisecond)
+	third, err := s.Put(Profile{Type: "cpu", StartedAt: now, Labels: map[string]string{"k": "v"}}, []byte("3"))
+	assert.Nil(t, err)
+
+	// the profiles are loaded back
+	s, err = NewDiskStore(dir)
+	assert.Nil(t, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []Profile{third, second}, list)
+	p, data, err := s.Get(third.ID)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, third, p)
+	assert.DeepEqual(t, "3", string(data))
+
+	_, _, err = s.Get("../profiles")
+	assert.DeepEqual(t, ErrNotFound, err)
+	_, err = s.Put(Profile{ID: "../profile"}, nil)
+	assert.NotNil(t, err)
+
+	// old profiles are dropped
+	s, err = NewDiskStore(dir, WithMaxAge(time.Hour))
+	assert.Nil(t, err)
+	_, err = s.Put(Profile{Type: "cpu", StartedAt: now.Add(-2 * time.Hour)}, []byte("old"))
+	assert.Nil(t, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []Profile{third, second}, list)
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, 4, len(files))
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"sync"
+)
+
+// MemoryStore is a Store keeping the latest profiles in memory.
+type MemoryStore struct {
+	max int
+
+	mu sync.RWMutex
+	// profiles are ordered from the oldest to the newest.
+	profiles []memoryProfile
+}
+
+type memoryProfile struct {
+	Profile
+	data []byte
+}
+
+// NewMemoryStore returns a MemoryStore keeping at most max profiles, the oldest ones
+// are dropped first. It panics if max is not positive.
+func NewMemoryStore(max int) *MemoryStore {
+	if max <= 0 {
+		panic("store: the maximum number of profiles must be positive")
+	}
+	return &MemoryStore{max: max}
+}
+
+// Put implements Store. The store keeps data, which must not be modified afterwards.
+func (s *MemoryStore) Put(p Profile, data []byte) (Profile, error) {
+	if p.ID == "" {
+		p.ID = NewID()
+	}
+	p.Size = int64(len(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles = append(s.profiles, memoryProfile{p, data})
+	if over := len(s.profiles) - s.max; over > 0 {
+		copy(s.profiles, s.profiles[over:])
+		for i := len(s.profiles) - over; i < len(s.profiles); i++ {
+			s.profiles[i] = memoryProfile{}
+		}
+		s.profiles = s.profiles[:len(s.profiles)-over]
+	}
+	return p, nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(id string) (Profile, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.profiles {
+		if p.ID == id {
+			return p.Profile, p.data, nil
+		}
+	}
+	return Profile{}, nil, ErrNotFound
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profiles := make([]Profile, 0, len(s.profiles))
+	for i := len(s.profiles) - 1; i >= 0; i-- {
+		profiles = append(profiles, s.profiles[i].Profile)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/test/assert"
+)
+
+func TestNewID(t *testing.T) {
+	first := NewID()
+	assert.DeepEqual(t, url.PathEscape(first), first)
+	assert.True(t, first != NewID())
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, first < NewID())
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2)
+
+	first, err := s.Put(Profile{Type: "cpu"}, []byte("first"))
+	assert.Nil(t, err)
+	assert.True(t, first.ID != "")
+	assert.DeepEqual(t, int64(5), first.Size)
+
+	second, err := s.Put(Profile{ID: "second", Type: "heap"}, []byte("second"))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, "second", second.ID)
+
+	p, data, err := s.Get(first.ID)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, first, p)
+	assert.DeepEqual(t, "first", string(data))
+
+	list, err := s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []Profile{second, first}, list)
+
+	// the oldest profile is dropped
+	third, err := s.Put(Profile{Type: "goroutine"}, []byte("third"))
+	assert.Nil(t, err)
+	_, _, err = s.Get(first.ID)
+	assert.DeepEqual(t, ErrNotFound, err)
+	list, err = s.List()
+	assert.Nil(t, err)
+	assert.DeepEqual(t, []Profile{third, second}, list)
+
+	assert.Panic(t, func() {
+		NewMemoryStore(0)
+	})
+}
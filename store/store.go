@@ -0,0 +1,67 @@
+/*
+ * Copyright 2023 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package store keeps the profiles captured in the background or on demand, so that they
+// can be listed and downloaded later through the routes registered with pprof.WithStore.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Get for an unknown profile.
+var ErrNotFound = errors.New("store: profile not found")
+
+// Profile describes a stored profile.
+type Profile struct {
+	// ID identifies the profile in the store, IDs sort in the order profiles were created.
+	ID string
+	// Type is the kind of profile, e.g. cpu, heap, goroutine, mutex, block or fgprof.
+	Type string
+	// Source tells what captured the profile, e.g. "request", "continuous" or "watcher".
+	Source string
+	// StartedAt is when the capture started.
+	StartedAt time.Time
+	// Duration is how long the capture lasted, 0 for a snapshot like a heap profile.
+	Duration time.Duration
+	// Size is the size of the profile in bytes.
+	Size int64
+	// Labels carries the context of the capture, e.g. the route of a profiled request.
+	Labels map[string]string
+}
+
+// Store keeps profiles, in the pprof protobuf format unless their type says otherwise.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Put stores data as the profile p, its ID is set with NewID if empty and its Size to
+	// the size of data. It returns the profile as stored.
+	Put(p Profile, data []byte) (Profile, error)
+	// Get returns the profile with the given ID, or ErrNotFound.
+	Get(id string) (Profile, []byte, error)
+	// List returns the stored profiles, newest first.
+	List() ([]Profile, error)
+}
+
+// NewID returns a new profile ID, made of the current UTC time and a random suffix,
+// e.g. "20230102T150405.000Z-1a2b3c4d", which is safe to use in urls and file names.
+func NewID() string {
+	var b [4]byte
+	rand.Read(b[:]) // nolint:errcheck
+	return time.Now().UTC().Format("20060102T150405.000Z") + "-" + hex.EncodeToString(b[:])
+}